@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/viteLiz/viteVm/vm"
+)
+
+func asmCmd(args []string) error {
+	flags := flag.NewFlagSet("asm", flag.ExitOnError)
+	file := flags.String("file", "", "file containing the source, stdin if not set")
+	flags.Parse(args)
+
+	var src []byte
+	var err error
+	if *file != "" {
+		src, err = ioutil.ReadFile(*file)
+	} else {
+		src, err = ioutil.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return err
+	}
+	code, err := vm.Assemble(string(src))
+	if err != nil {
+		return err
+	}
+	fmt.Println(hex.EncodeToString(code))
+	return nil
+}
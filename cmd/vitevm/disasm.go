@@ -0,0 +1,22 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/viteLiz/viteVm/vm"
+)
+
+func disasmCmd(args []string) error {
+	flags := flag.NewFlagSet("disasm", flag.ExitOnError)
+	code := flags.String("code", "", "hex encoded code")
+	file := flags.String("file", "", "file containing hex encoded code")
+	flags.Parse(args)
+
+	data, err := readCode(*code, *file)
+	if err != nil {
+		return err
+	}
+	fmt.Print(vm.DisassembleString(data))
+	return nil
+}
@@ -0,0 +1,77 @@
+// Command vitevm works with Vite VM bytecode.
+//
+//	vitevm asm [-file path]
+//	vitevm disasm [-code hex | -file path]
+//	vitevm run [-code hex | -file path] [-create] [-prestate path] [flags]
+//	vitevm statetest [-run name] file...
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"asm":       {"assemble mnemonics into hex encoded code", asmCmd},
+	"disasm":    {"disassemble code, split into init code, runtime code and metadata", disasmCmd},
+	"run":       {"run code or a create transaction against a JSON prestate", runCmd},
+	"statetest": {"run JSON state test fixtures", stateTestCmd},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "vitevm %v: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(os.Stderr, "usage: vitevm <command> [flags]\n\ncommands:\n")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10v %v\n", name, commands[name].usage)
+	}
+}
+
+// readCode returns the hex encoded code given with -code, read from -file or,
+// without either, from stdin.
+func readCode(code, file string) ([]byte, error) {
+	input := []byte(code)
+	if code == "" {
+		var err error
+		if file != "" {
+			input, err = ioutil.ReadFile(file)
+		} else {
+			input, err = ioutil.ReadAll(os.Stdin)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	s := strings.TrimPrefix(string(bytes.TrimSpace(input)), "0x")
+	return hex.DecodeString(s)
+}
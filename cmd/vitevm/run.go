@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/viteLiz/viteVm/vm"
+	"github.com/vitelabs/go-vite/common/types"
+)
+
+func runCmd(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	code := flags.String("code", "", "hex encoded code, installed at -to unless -create is set")
+	file := flags.String("file", "", "file containing hex encoded code")
+	create := flags.Bool("create", false, "run the code as the creation code of a new contract")
+	input := flags.String("input", "", "hex encoded call data, appended to the code on -create")
+	prestate := flags.String("prestate", "", "JSON file with the state to run against")
+	poststate := flags.String("poststate", "", "JSON file to write the state after the run to, printed with the result if not set")
+	from := flags.String("from", "", "sender address")
+	to := flags.String("to", "", "receiver address, derived from the sender on -create")
+	amount := flags.String("amount", "0", "amount transferred, in the smallest unit")
+	token := flags.String("token", "", "token type id of -amount, the Vite token if not set")
+	txType := flags.Int("txtype", vm.TxTypeReceive, "transaction type, 1 send, 2 receive, 3 send emitted by CREATE")
+	height := flags.Uint64("height", 1, "account height of the transaction")
+	snapshotHeight := flags.Uint64("snapshotheight", 1, "snapshot block height")
+	timestamp := flags.Uint64("timestamp", 0, "snapshot block timestamp")
+	quota := flags.Uint64("quota", vm.DefaultQuotaParams.MaxQuota, "quota available to the transaction")
+	quotaSchedule := flags.String("quotaschedule", "", "JSON or TOML file repricing the quota schedule")
+	maxSteps := flags.Uint64("maxsteps", 0, "abort after executing this many opcodes, 0 for no limit")
+	timeout := flags.Duration("timeout", 0, "abort after running this long, 0 for no limit")
+	trace := flags.Bool("trace", false, "write a JSON trace to stderr")
+	profile := flags.String("profile", "", "file to write the quota and time spent per opcode and pc to")
+	flamegraph := flags.String("flamegraph", "", "file to write the quota per call stack to, in the collapsed format of flamegraph.pl")
+	flags.Parse(args)
+
+	db := vm.NewMemoryDatabase()
+	if *prestate != "" {
+		data, err := ioutil.ReadFile(*prestate)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, db); err != nil {
+			return fmt.Errorf("invalid prestate: %v", err)
+		}
+	}
+
+	tx := vm.Transaction{
+		TxType:            *txType,
+		Depth:             1,
+		AccountHeight:     new(big.Int).SetUint64(*height),
+		SnapshotHeight:    new(big.Int).SetUint64(*snapshotHeight),
+		SnapshotTimestamp: new(big.Int).SetUint64(*timestamp),
+	}
+	var err error
+	if *from != "" {
+		if tx.From, err = types.HexToAddress(*from); err != nil {
+			return fmt.Errorf("invalid -from: %v", err)
+		}
+	}
+	if *to != "" {
+		if tx.To, err = types.HexToAddress(*to); err != nil {
+			return fmt.Errorf("invalid -to: %v", err)
+		}
+	}
+	if *token != "" {
+		if tx.TokenTypeId, err = types.HexToTokenTypeId(*token); err != nil {
+			return fmt.Errorf("invalid -token: %v", err)
+		}
+	}
+	var ok bool
+	if tx.Amount, ok = new(big.Int).SetString(*amount, 10); !ok || tx.Amount.Sign() < 0 {
+		return fmt.Errorf("invalid -amount %q", *amount)
+	}
+	if tx.Data, err = hex.DecodeString(strings.TrimPrefix(*input, "0x")); err != nil {
+		return fmt.Errorf("invalid -input: %v", err)
+	}
+
+	var contractCode []byte
+	if *code != "" || *file != "" {
+		if contractCode, err = readCode(*code, *file); err != nil {
+			return err
+		}
+	}
+	if *create {
+		tx.Data = append(contractCode, tx.Data...)
+	} else if contractCode != nil {
+		db.SetContractCode(tx.To, contractCode)
+	}
+
+	config := *vm.DefaultChainConfig
+	if *quotaSchedule != "" {
+		s, err := vm.LoadQuotaSchedule(*quotaSchedule)
+		if err != nil {
+			return err
+		}
+		config.QuotaSchedules = []vm.QuotaScheduleUpgrade{{Height: big.NewInt(0), Schedule: s}}
+	}
+	v := vm.NewVMWithChainConfig(tx, &config)
+	v.StateDb = db
+	v.QuotaProvider = vm.FixedQuotaProvider(*quota)
+	v.MaxSteps = *maxSteps
+	if *timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		v.Context = ctx
+	}
+	if *trace {
+		v.Tracer = vm.NewJSONLogger(os.Stderr)
+	}
+	if *profile != "" || *flamegraph != "" {
+		v.Profiler = vm.NewProfiler()
+	}
+	var result *vm.ExecutionResult
+	if *create {
+		result, _ = v.Create()
+	} else {
+		result, _ = v.Call()
+	}
+
+	if *profile != "" {
+		if err := writeFile(*profile, v.Profiler.WriteReport); err != nil {
+			return err
+		}
+	}
+	if *flamegraph != "" {
+		if err := writeFile(*flamegraph, v.Profiler.WriteCollapsed); err != nil {
+			return err
+		}
+	}
+
+	out := struct {
+		Result    *vm.ExecutionResult `json:"result"`
+		PostState *vm.MemoryDatabase  `json:"poststate,omitempty"`
+	}{Result: result}
+	if *poststate != "" {
+		data, err := json.MarshalIndent(db, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(*poststate, data, 0644); err != nil {
+			return err
+		}
+	} else {
+		out.PostState = db
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// writeFile creates file and writes it with write.
+func writeFile(file string, write func(io.Writer) error) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/viteLiz/viteVm/vm"
+)
+
+func stateTestCmd(args []string) error {
+	flags := flag.NewFlagSet("statetest", flag.ExitOnError)
+	run := flags.String("run", "", "only run tests whose name contains this")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		return errors.New("no state test files given")
+	}
+
+	passed, failed := 0, 0
+	for _, file := range flags.Args() {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		var tests map[string]*vm.StateTest
+		if err := json.Unmarshal(data, &tests); err != nil {
+			return fmt.Errorf("%v: %v", file, err)
+		}
+		names := make([]string, 0, len(tests))
+		for name := range tests {
+			if strings.Contains(name, *run) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := tests[name].Run(); err != nil {
+				failed++
+				fmt.Printf("FAIL %v %v\n\t%v\n", file, name, strings.Replace(err.Error(), "\n", "\n\t", -1))
+			} else {
+				passed++
+				fmt.Printf("PASS %v %v\n", file, name)
+			}
+		}
+	}
+	fmt.Printf("%v passed, %v failed\n", passed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%v state tests failed", failed)
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package vm
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Assemble turns mnemonic source into code. Tokens are separated by white
+// space and ';' starts a comment running to the end of the line:
+//
+//	PUSH 0x20          ; PUSH of the smallest width holding the value
+//	PUSH2 1            ; PUSH of a fixed width
+//	loop:              ; label, emits a JUMPDEST
+//	PUSH @loop JUMP    ; @name pushes the offset of a label or data section
+//	PUSH #code         ; #name pushes the size of a data section
+//	.data code 0x6000  ; data section, raw bytes
+//
+// Mnemonics are case-insensitive and checked against the latest instruction
+// set, so the code contains no invalid opcodes outside of data for a VM with
+// every upgrade active.
+func Assemble(src string) ([]byte, error) {
+	items, err := parseAsm(src)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make(map[string]*asmItem)
+	for _, item := range items {
+		if item.label == "" {
+			continue
+		}
+		if _, ok := labels[item.label]; ok {
+			return nil, fmt.Errorf("line %v: label %v already defined", item.line, item.label)
+		}
+		labels[item.label] = item
+	}
+	resolve := func(item *asmItem) (*word, error) {
+		if item.ref == "" {
+			return &item.arg, nil
+		}
+		label, ok := labels[item.ref]
+		if !ok {
+			return nil, fmt.Errorf("line %v: undefined label %v", item.line, item.ref)
+		}
+		if item.size {
+			if !label.isData {
+				return nil, fmt.Errorf("line %v: #%v is not a data section", item.line, item.ref)
+			}
+			return new(word).setUint64(uint64(len(label.data))), nil
+		}
+		return new(word).setUint64(label.pc), nil
+	}
+
+	// pushes of labels start out as PUSH1 and are widened until every
+	// offset fits, widening moves the labels after them
+	for changed := true; changed; {
+		changed = false
+		pc := uint64(0)
+		for _, item := range items {
+			item.pc = pc
+			pc += item.len()
+		}
+		for _, item := range items {
+			if item.ref == "" || item.fixedWidth {
+				continue
+			}
+			value, err := resolve(item)
+			if err != nil {
+				return nil, err
+			}
+			if width := pushWidth(value); width > item.width {
+				item.width, changed = width, true
+			}
+		}
+	}
+
+	var code []byte
+	for _, item := range items {
+		if item.isData {
+			code = append(code, item.data...)
+			continue
+		}
+		code = append(code, byte(item.op))
+		if item.op == PUSH1 {
+			value, err := resolve(item)
+			if err != nil {
+				return nil, err
+			}
+			if pushWidth(value) > item.width {
+				return nil, fmt.Errorf("line %v: %#x does not fit in PUSH%v", item.line, value.big(), item.width)
+			}
+			code[len(code)-1] = byte(PUSH1) + byte(item.width-1)
+			b := value.bytes32()
+			code = append(code, b[32-item.width:]...)
+		}
+	}
+	return code, nil
+}
+
+// asmItem is an instruction, label or data section of assembler source.
+type asmItem struct {
+	line  int
+	pc    uint64
+	op    OpCode // PUSH1 for every PUSH
+	label string // name of a label or data section
+
+	// PUSH argument: a constant or the offset or size of a label
+	arg        word
+	ref        string
+	size       bool
+	width      int
+	fixedWidth bool
+
+	isData bool
+	data   []byte
+}
+
+func (item *asmItem) len() uint64 {
+	switch {
+	case item.isData:
+		return uint64(len(item.data))
+	case item.op == PUSH1:
+		return 1 + uint64(item.width)
+	}
+	return 1
+}
+
+// pushWidth returns the width of the smallest PUSH holding value.
+func pushWidth(value *word) int {
+	if value.isZero() {
+		return 1
+	}
+	return (value.bitLen() + 7) / 8
+}
+
+func parseAsm(src string) ([]*asmItem, error) {
+	type token struct {
+		text string
+		line int
+	}
+	var tokens []token
+	for i, line := range strings.Split(src, "\n") {
+		if comment := strings.IndexByte(line, ';'); comment >= 0 {
+			line = line[:comment]
+		}
+		for _, text := range strings.Fields(line) {
+			tokens = append(tokens, token{text, i + 1})
+		}
+	}
+
+	var items []*asmItem
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		next := func() (string, error) {
+			if i+1 >= len(tokens) {
+				return "", fmt.Errorf("line %v: missing argument of %v", tok.line, tok.text)
+			}
+			i++
+			return tokens[i].text, nil
+		}
+
+		switch name := strings.ToUpper(tok.text); {
+		case strings.HasSuffix(tok.text, ":"):
+			label := strings.TrimSuffix(tok.text, ":")
+			if !isAsmIdentifier(label) {
+				return nil, fmt.Errorf("line %v: invalid label %q", tok.line, label)
+			}
+			items = append(items, &asmItem{line: tok.line, op: JUMPDEST, label: label})
+
+		case tok.text == ".data":
+			label, err := next()
+			if err != nil {
+				return nil, err
+			}
+			if !isAsmIdentifier(label) {
+				return nil, fmt.Errorf("line %v: invalid data section name %q", tok.line, label)
+			}
+			arg, err := next()
+			if err != nil {
+				return nil, err
+			}
+			data, err := hex.DecodeString(strings.TrimPrefix(arg, "0x"))
+			if err != nil {
+				return nil, fmt.Errorf("line %v: invalid data %q, %v", tok.line, arg, err)
+			}
+			items = append(items, &asmItem{line: tok.line, label: label, isData: true, data: data})
+
+		case strings.HasPrefix(name, "PUSH"):
+			item := &asmItem{line: tok.line, op: PUSH1, width: 1}
+			if name != "PUSH" {
+				op, ok := stringToOp[name]
+				if !ok || !op.isPush() {
+					return nil, fmt.Errorf("line %v: unknown instruction %v", tok.line, tok.text)
+				}
+				item.width, item.fixedWidth = int(op-PUSH1)+1, true
+			}
+			arg, err := next()
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case strings.HasPrefix(arg, "@"):
+				item.ref = arg[1:]
+			case strings.HasPrefix(arg, "#"):
+				item.ref, item.size = arg[1:], true
+			default:
+				value, ok := new(big.Int).SetString(arg, 0)
+				if !ok || value.Sign() < 0 || value.BitLen() > 256 {
+					return nil, fmt.Errorf("line %v: invalid PUSH argument %q", tok.line, arg)
+				}
+				item.arg.setBig(value)
+				if !item.fixedWidth {
+					item.width = pushWidth(&item.arg)
+				}
+			}
+			items = append(items, item)
+
+		default:
+			op, ok := stringToOp[name]
+			if !ok {
+				return nil, fmt.Errorf("line %v: unknown instruction %v", tok.line, tok.text)
+			}
+			if !simpleInstructionSet[op].valid {
+				return nil, fmt.Errorf("line %v: %v is not in the instruction set", tok.line, op)
+			}
+			items = append(items, &asmItem{line: tok.line, op: op})
+		}
+	}
+	return items, nil
+}
+
+func isAsmIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package vm
+
+import (
+	"encoding/hex"
+	"github.com/vitelabs/go-vite/common/types"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestAssemble(t *testing.T) {
+	tests := []struct {
+		src  string
+		code string
+	}{
+		// the code of TestRun
+		{"PUSH 1 PUSH 2 ADD PUSH 32 DUP1 SWAP2 SWAP1 MSTORE PUSH 32 SWAP1 RETURN", "6001600201602080919052602090f3"},
+		{"push 0 push 0x100 PUSH4 0xff PUSH32 1", "600061010063000000ff7f" + strings.Repeat("00", 31) + "01"},
+		{"start: ; a comment\n\tPUSH @start PUSH @end JUMP\nend:", "5b6000600656" + "5b"},
+		// the jump over the data needs a PUSH2, moving end
+		{"PUSH @end JUMP .data pad 0x" + strings.Repeat("00", 300) + " end: STOP", "61013056" + strings.Repeat("00", 300) + "5b00"},
+		{"PUSH #code PUSH @code PUSH 0 CODECOPY PUSH #code PUSH 0 RETURN .data code 6000", "6002600c60003960026000f36000"},
+	}
+	for i, test := range tests {
+		code, err := Assemble(test.src)
+		if err != nil {
+			t.Fatalf("test %v: assemble failed, %v", i, err)
+		}
+		if hex.EncodeToString(code) != test.code {
+			t.Errorf("test %v: expected %v, got %x", i, test.code, code)
+		}
+	}
+
+	errors := []struct {
+		src string
+		err string
+	}{
+		{"PUSH 1\nFOO", "line 2: unknown instruction FOO"},
+		{"GAS", "line 1: GAS is not in the instruction set"},
+		{"PUSH @nowhere", "line 1: undefined label nowhere"},
+		{"a: a:", "line 1: label a already defined"},
+		{"a: PUSH #a", "line 1: #a is not a data section"},
+		{"PUSH1 256", "line 1: 0x100 does not fit in PUSH1"},
+		{"PUSH -1", "line 1: invalid PUSH argument \"-1\""},
+		{"PUSH", "line 1: missing argument of PUSH"},
+		{"PUSH33 1", "line 1: unknown instruction PUSH33"},
+		{"1a:", "line 1: invalid label \"1a\""},
+	}
+	for i, test := range errors {
+		if _, err := Assemble(test.src); err == nil || err.Error() != test.err {
+			t.Errorf("error test %v: expected %q, got %v", i, test.err, err)
+		}
+	}
+}
+
+func TestAssembleRun(t *testing.T) {
+	// sum 1..10 in a loop, return it
+	code, err := Assemble(`
+		PUSH 0 PUSH 10         ; sum, i
+	loop:
+		DUP1 ISZERO PUSH @done JUMPI
+		DUP1 SWAP2 ADD SWAP1   ; sum += i
+		PUSH 1 SWAP1 SUB       ; i--
+		PUSH @loop JUMP
+	done:
+		POP PUSH 0 MSTORE PUSH 32 PUSH 0 RETURN
+	`)
+	if err != nil {
+		t.Fatalf("assemble failed, %v", err)
+	}
+	vm := NewVM(Transaction{Depth: 1})
+	vm.StateDb = &testDatabase{}
+	vm.quotaLeft = 1000000
+	c := newContract(types.Address{}, types.Address{}, types.TokenTypeId{}, new(big.Int), code)
+	c.setCallCode(types.Address{}, types.Hash{}, code)
+	ret, err := run(vm, c)
+	if err != nil || new(big.Int).SetBytes(ret).Cmp(big.NewInt(55)) != 0 {
+		t.Fatalf("expected 55, got %x, %v", ret, err)
+	}
+}
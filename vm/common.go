@@ -1,6 +1,7 @@
 package vm
 
 import (
+	"github.com/vitelabs/go-vite/common/types"
 	"math/big"
 )
 
@@ -66,23 +67,71 @@ func leftPadBytes(slice []byte, l int) []byte {
 	return padded
 }
 
-// calculates the memory size required for a step
-func calcMemSize(off, l *big.Int) *big.Int {
-	if l.Sign() == 0 {
-		return big0
+// heightBytes returns height as 32 big-endian bytes, a nil height is zero.
+func heightBytes(height *big.Int) []byte {
+	if height == nil {
+		return make([]byte, 32)
 	}
+	return leftPadBytes(height.Bytes(), 32)
+}
 
-	return new(big.Int).Add(off, l)
+// calcMemSize returns the memory size required for a step and whether it
+// overflowed a uint64.
+func calcMemSize(off, l *word) (uint64, bool) {
+	if l.isZero() {
+		return 0, false
+	}
+	length, overflow := l.uint64WithOverflow()
+	if overflow {
+		return 0, true
+	}
+	return calcMemSizeUint64(off, length)
+}
+
+// calcMemSizeUint64 is calcMemSize for a length known to fit in a uint64.
+func calcMemSizeUint64(off *word, length uint64) (uint64, bool) {
+	if length == 0 {
+		return 0, false
+	}
+	offset, overflow := off.uint64WithOverflow()
+	if overflow {
+		return 0, true
+	}
+	return SafeAdd(offset, length)
 }
 
-// getDataBig returns a slice from the data based on the start and size and pads
+// getData returns a slice from the data based on the start and size and pads
 // up to size with zero's. This function is overflow safe.
-func getDataBig(data []byte, start *big.Int, size *big.Int) []byte {
-	dlen := big.NewInt(int64(len(data)))
+func getData(data []byte, start uint64, size uint64) []byte {
+	length := uint64(len(data))
+	if start > length {
+		start = length
+	}
+	end := start + size
+	if end > length || end < start {
+		end = length
+	}
+	return rightPadBytes(data[start:end], int(size))
+}
+
+// wordToAddress returns the address held in the lowest bytes of w.
+func wordToAddress(w *word) types.Address {
+	b := w.bytes32()
+	addr, _ := types.BytesToAddress(b[32-types.AddressSize:])
+	return addr
+}
+
+// wordToTokenTypeId returns the token type id held in the lowest bytes of w.
+func wordToTokenTypeId(w *word) types.TokenTypeId {
+	b := w.bytes32()
+	tokenTypeId, _ := types.BytesToTokenTypeId(b[32-types.TokenTypeIdSize:])
+	return tokenTypeId
+}
 
-	s := BigMin(start, dlen)
-	e := BigMin(new(big.Int).Add(s, size), dlen)
-	return rightPadBytes(data[s.Uint64():e.Uint64()], int(size.Uint64()))
+func wordToHash(w *word) types.Hash {
+	b := w.bytes32()
+	hash, _ := types.BytesToHash(b[:])
+	return hash
 }
 
 func min(x, y uint64) uint64 {
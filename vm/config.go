@@ -0,0 +1,61 @@
+package vm
+
+import "math/big"
+
+// ChainConfig holds the snapshot heights at which named upgrades of the
+// execution rules activate, nil for upgrades that are not scheduled. A VM
+// executes a transaction with the rules in effect at its SnapshotHeight, so
+// transactions before and after an upgrade replay as they were executed.
+type ChainConfig struct {
+	// LeafHeight activates STATICCALL and the precompiled contracts
+	// reachable with DELEGATECALL and STATICCALL.
+	LeafHeight *big.Int `json:"leafHeight,omitempty"`
+
+	// QuotaSchedules reprice execution from their height on. The schedule
+	// with the highest height active at a snapshot height is in effect,
+	// DefaultQuotaSchedule before the first.
+	QuotaSchedules []QuotaScheduleUpgrade `json:"quotaSchedules,omitempty"`
+}
+
+// QuotaScheduleUpgrade activates a quota schedule at a snapshot height.
+type QuotaScheduleUpgrade struct {
+	Height   *big.Int       `json:"height"`
+	Schedule *QuotaSchedule `json:"schedule"` // DefaultQuotaSchedule if nil
+}
+
+// DefaultChainConfig has every upgrade active from the first snapshot.
+var DefaultChainConfig = &ChainConfig{
+	LeafHeight: big.NewInt(0),
+}
+
+// IsLeaf returns whether the Leaf upgrade is active at snapshot height h.
+func (c *ChainConfig) IsLeaf(h *big.Int) bool {
+	return isActive(c.LeafHeight, h)
+}
+
+// quotaSchedule returns the quota schedule in effect at snapshot height h.
+func (c *ChainConfig) quotaSchedule(h *big.Int) *QuotaSchedule {
+	var active *QuotaScheduleUpgrade
+	for i := range c.QuotaSchedules {
+		u := &c.QuotaSchedules[i]
+		if isActive(u.Height, h) && (active == nil || u.Height.Cmp(active.Height) >= 0) {
+			active = u
+		}
+	}
+	if active == nil || active.Schedule == nil {
+		return &defaultQuotaSchedule
+	}
+	return active.Schedule
+}
+
+// isActive returns whether an upgrade activated at height s is active at
+// height h, a nil h being the first snapshot.
+func isActive(s, h *big.Int) bool {
+	if s == nil {
+		return false
+	}
+	if h == nil {
+		return s.Sign() <= 0
+	}
+	return s.Cmp(h) <= 0
+}
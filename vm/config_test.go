@@ -0,0 +1,97 @@
+package vm
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestChainConfig(t *testing.T) {
+	config := &ChainConfig{LeafHeight: big.NewInt(10)}
+	tests := []struct {
+		config *ChainConfig
+		height *big.Int
+		leaf   bool
+	}{
+		{config, nil, false},
+		{config, big.NewInt(9), false},
+		{config, big.NewInt(10), true},
+		{config, big.NewInt(11), true},
+		{DefaultChainConfig, nil, true},
+		{DefaultChainConfig, big.NewInt(0), true},
+		{&ChainConfig{}, big.NewInt(100), false},
+	}
+	for i, test := range tests {
+		if leaf := test.config.IsLeaf(test.height); leaf != test.leaf {
+			t.Errorf("test %v: expected IsLeaf %v, got %v", i, test.leaf, leaf)
+		}
+		vm := NewVMWithChainConfig(Transaction{SnapshotHeight: test.height}, test.config)
+		if vm.instructionSet[STATICCALL].valid != test.leaf || (vm.precompiles[identityAddress] != nil) != test.leaf {
+			t.Errorf("test %v: expected the rules of Leaf to be active %v", i, test.leaf)
+		}
+	}
+}
+
+func TestChainConfig_QuotaSchedule(t *testing.T) {
+	first, second := *DefaultQuotaSchedule(), *DefaultQuotaSchedule()
+	first.Tx, second.Tx = 1000, 2000
+	config := &ChainConfig{QuotaSchedules: []QuotaScheduleUpgrade{
+		{big.NewInt(20), &second},
+		{big.NewInt(10), &first},
+		{nil, &second},
+	}}
+	tests := []struct {
+		height *big.Int
+		tx     uint64
+	}{
+		{nil, txGas},
+		{big.NewInt(9), txGas},
+		{big.NewInt(10), 1000},
+		{big.NewInt(19), 1000},
+		{big.NewInt(20), 2000},
+		{big.NewInt(25), 2000},
+	}
+	for _, test := range tests {
+		vm := NewVMWithChainConfig(Transaction{SnapshotHeight: test.height}, config)
+		if vm.quotaSchedule.Tx != test.tx {
+			t.Errorf("height %v: expected the schedule charging %v per transaction, got %v", test.height, test.tx, vm.quotaSchedule.Tx)
+		}
+	}
+}
+
+func TestDefaultQuotaSchedule_Copy(t *testing.T) {
+	s := DefaultQuotaSchedule()
+	s.Tx = 1000
+	if vm := NewVM(Transaction{}); vm.quotaSchedule.Tx != txGas {
+		t.Errorf("expected changing a copy of the default schedule to leave the VM charging %v per transaction, got %v", txGas, vm.quotaSchedule.Tx)
+	}
+	if DefaultQuotaSchedule().Tx != txGas {
+		t.Error("expected each copy of the default schedule to start from the defaults")
+	}
+}
+
+func TestChainConfig_UnmarshalJSON(t *testing.T) {
+	var config ChainConfig
+	if err := json.Unmarshal([]byte(`{"quotaSchedules": [{"height": 10, "schedule": {"tx": 1000}}]}`), &config); err != nil {
+		t.Fatal(err)
+	}
+	expected := *DefaultQuotaSchedule()
+	expected.Tx = 1000
+	if s := config.quotaSchedule(big.NewInt(10)); *s != expected {
+		t.Errorf("expected the costs left out to be the defaults, got %+v", *s)
+	}
+
+	for _, test := range []struct {
+		schedule string
+		err      string
+	}{
+		{`{"quadCoeffDiv": 0}`, "quadCoeffDiv must not be zero"},
+		{`{"txx": 1000}`, `json: unknown field "txx"`},
+	} {
+		data := `{"quotaSchedules": [{"height": 10, "schedule": ` + test.schedule + `}]}`
+		if err := json.Unmarshal([]byte(data), new(ChainConfig)); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%v: expected error %q, got %v", test.schedule, test.err, err)
+		}
+	}
+}
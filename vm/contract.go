@@ -21,8 +21,8 @@ func newContract(caller types.Address, address types.Address, tokenId types.Toke
 	return &contract{caller: caller, address: address, tokenId: tokenId, amount: amount, data: data, jumpdests: make(destinations)}
 }
 
-func (c *contract) getOp(n uint64) opCode {
-	return opCode(c.getByte(n))
+func (c *contract) getOp(n uint64) OpCode {
+	return OpCode(c.getByte(n))
 }
 
 func (c *contract) getByte(n uint64) byte {
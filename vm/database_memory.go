@@ -0,0 +1,324 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/vitelabs/go-vite/common/types"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// MemoryDatabase is an in-memory Database for tests and tooling. Every change
+// is journaled so that RevertToSnapShot really undoes everything done since
+// the matching Snapshot. Commit marks the end of a transaction for
+// GetCommittedState. It is not safe for concurrent use.
+type MemoryDatabase struct {
+	accounts map[types.Address]*memoryAccount
+	hashes   map[uint64]types.Hash
+	journal  []func()
+	origins  map[types.Address]map[types.Hash]types.Hash // committed values of the slots changed since Commit
+}
+
+type memoryAccount struct {
+	balances map[types.TokenTypeId]*big.Int
+	code     []byte
+	codeHash types.Hash
+	storage  map[types.Hash]types.Hash
+}
+
+func newMemoryAccount() *memoryAccount {
+	return &memoryAccount{balances: make(map[types.TokenTypeId]*big.Int), storage: make(map[types.Hash]types.Hash)}
+}
+
+func NewMemoryDatabase() *MemoryDatabase {
+	return &MemoryDatabase{accounts: make(map[types.Address]*memoryAccount), hashes: make(map[uint64]types.Hash)}
+}
+
+// account returns the account at addr, creating it if it does not exist.
+func (db *MemoryDatabase) account(addr types.Address) *memoryAccount {
+	if account, ok := db.accounts[addr]; ok {
+		return account
+	}
+	account := newMemoryAccount()
+	db.accounts[addr] = account
+	db.journal = append(db.journal, func() { delete(db.accounts, addr) })
+	return account
+}
+
+func (db *MemoryDatabase) GetBalance(addr types.Address, tokenTypeId types.TokenTypeId) *big.Int {
+	if account, ok := db.accounts[addr]; ok {
+		if balance, ok := account.balances[tokenTypeId]; ok {
+			return new(big.Int).Set(balance)
+		}
+	}
+	return new(big.Int)
+}
+
+func (db *MemoryDatabase) SubBalance(addr types.Address, tokenTypeId types.TokenTypeId, amount *big.Int) {
+	db.setBalance(addr, tokenTypeId, new(big.Int).Sub(db.GetBalance(addr, tokenTypeId), amount))
+}
+
+func (db *MemoryDatabase) AddBalance(addr types.Address, tokenTypeId types.TokenTypeId, amount *big.Int) {
+	db.setBalance(addr, tokenTypeId, new(big.Int).Add(db.GetBalance(addr, tokenTypeId), amount))
+}
+
+func (db *MemoryDatabase) setBalance(addr types.Address, tokenTypeId types.TokenTypeId, balance *big.Int) {
+	account := db.account(addr)
+	prev, ok := account.balances[tokenTypeId]
+	db.journal = append(db.journal, func() {
+		if ok {
+			account.balances[tokenTypeId] = prev
+		} else {
+			delete(account.balances, tokenTypeId)
+		}
+	})
+	account.balances[tokenTypeId] = balance
+}
+
+// Snapshot returns an id to revert all later changes with RevertToSnapShot.
+func (db *MemoryDatabase) Snapshot() int {
+	return len(db.journal)
+}
+
+func (db *MemoryDatabase) RevertToSnapShot(revertId int) {
+	for i := len(db.journal) - 1; i >= revertId; i-- {
+		db.journal[i]()
+	}
+	db.journal = db.journal[:revertId]
+}
+
+func (db *MemoryDatabase) IsExistAddress(addr types.Address) bool {
+	_, ok := db.accounts[addr]
+	return ok
+}
+
+func (db *MemoryDatabase) CreateAccount(addr types.Address) {
+	db.account(addr)
+}
+
+func (db *MemoryDatabase) DeleteAccount(addr types.Address) {
+	if account, ok := db.accounts[addr]; ok {
+		for loc, value := range account.storage {
+			db.recordOrigin(addr, loc, value)
+		}
+		delete(db.accounts, addr)
+		db.journal = append(db.journal, func() { db.accounts[addr] = account })
+	}
+}
+
+func (db *MemoryDatabase) SetContractCode(addr types.Address, code []byte) {
+	account := db.account(addr)
+	prevCode, prevHash := account.code, account.codeHash
+	db.journal = append(db.journal, func() { account.code, account.codeHash = prevCode, prevHash })
+	account.code, account.codeHash = code, types.DataHash(code)
+}
+
+func (db *MemoryDatabase) GetContractCode(addr types.Address) []byte {
+	if account, ok := db.accounts[addr]; ok {
+		return account.code
+	}
+	return nil
+}
+
+func (db *MemoryDatabase) GetContractCodeSize(addr types.Address) int {
+	return len(db.GetContractCode(addr))
+}
+
+func (db *MemoryDatabase) GetContractCodeHash(addr types.Address) types.Hash {
+	if account, ok := db.accounts[addr]; ok {
+		return account.codeHash
+	}
+	return types.Hash{}
+}
+
+func (db *MemoryDatabase) GetState(addr types.Address, loc types.Hash) types.Hash {
+	if account, ok := db.accounts[addr]; ok {
+		return account.storage[loc]
+	}
+	return types.Hash{}
+}
+
+// GetCommittedState returns the value of loc at the last Commit, or when db
+// was created or decoded.
+func (db *MemoryDatabase) GetCommittedState(addr types.Address, loc types.Hash) types.Hash {
+	if value, ok := db.origins[addr][loc]; ok {
+		return value
+	}
+	return db.GetState(addr, loc)
+}
+
+// Commit makes the current state the committed state returned by
+// GetCommittedState. It is called between transactions executed on db.
+func (db *MemoryDatabase) Commit() {
+	db.origins = nil
+}
+
+// recordOrigin remembers value as the committed value of loc unless loc
+// changed before since Commit. Reverting a change keeps the record, the
+// committed value is the same either way.
+func (db *MemoryDatabase) recordOrigin(addr types.Address, loc types.Hash, value types.Hash) {
+	if db.origins == nil {
+		db.origins = make(map[types.Address]map[types.Hash]types.Hash)
+	}
+	slots, ok := db.origins[addr]
+	if !ok {
+		slots = make(map[types.Hash]types.Hash)
+		db.origins[addr] = slots
+	}
+	if _, ok := slots[loc]; !ok {
+		slots[loc] = value
+	}
+}
+
+// SetState stores value at loc, storing a zero value deletes loc.
+func (db *MemoryDatabase) SetState(addr types.Address, loc types.Hash, value types.Hash) {
+	account := db.account(addr)
+	prev, ok := account.storage[loc]
+	db.recordOrigin(addr, loc, prev)
+	db.journal = append(db.journal, func() {
+		if ok {
+			account.storage[loc] = prev
+		} else {
+			delete(account.storage, loc)
+		}
+	})
+	if value == (types.Hash{}) {
+		delete(account.storage, loc)
+	} else {
+		account.storage[loc] = value
+	}
+}
+
+// GetStatesString returns the storage of addr as loc=>value pairs sorted by loc.
+func (db *MemoryDatabase) GetStatesString(addr types.Address) string {
+	account, ok := db.accounts[addr]
+	if !ok {
+		return ""
+	}
+	locs := make([]types.Hash, 0, len(account.storage))
+	for loc := range account.storage {
+		locs = append(locs, loc)
+	}
+	sort.Slice(locs, func(i, j int) bool { return bytes.Compare(locs[i].Bytes(), locs[j].Bytes()) < 0 })
+	states := make([]string, len(locs))
+	for i, loc := range locs {
+		states[i] = loc.Hex() + "=>" + account.storage[loc].Hex()
+	}
+	return strings.Join(states, ", ")
+}
+
+func (db *MemoryDatabase) GetHash(num uint64) types.Hash {
+	return db.hashes[num]
+}
+
+// SetHash sets the snapshot block hash returned by GetHash for num. It is
+// part of the environment rather than the state and is not journaled.
+func (db *MemoryDatabase) SetHash(num uint64, hash types.Hash) {
+	db.hashes[num] = hash
+}
+
+type jsonMemoryDatabase struct {
+	Accounts map[string]jsonMemoryAccount `json:"accounts"`
+	Hashes   map[uint64]string            `json:"hashes,omitempty"`
+}
+
+type jsonMemoryAccount struct {
+	Balances map[string]string `json:"balances,omitempty"`
+	Code     string            `json:"code,omitempty"`
+	Storage  map[string]string `json:"storage,omitempty"`
+}
+
+// MarshalJSON encodes the accounts keyed by address, balances in decimal keyed
+// by token type id, code as 0x-prefixed hex and storage as hex hashes, and
+// the snapshot block hashes keyed by height.
+func (db *MemoryDatabase) MarshalJSON() ([]byte, error) {
+	enc := jsonMemoryDatabase{Accounts: make(map[string]jsonMemoryAccount, len(db.accounts))}
+	for addr, account := range db.accounts {
+		var acc jsonMemoryAccount
+		if len(account.balances) > 0 {
+			acc.Balances = make(map[string]string, len(account.balances))
+			for tokenTypeId, balance := range account.balances {
+				acc.Balances[tokenTypeId.String()] = balance.String()
+			}
+		}
+		if len(account.code) > 0 {
+			acc.Code = hexBytes(account.code)
+		}
+		if len(account.storage) > 0 {
+			acc.Storage = make(map[string]string, len(account.storage))
+			for loc, value := range account.storage {
+				acc.Storage[loc.Hex()] = value.Hex()
+			}
+		}
+		enc.Accounts[addr.String()] = acc
+	}
+	if len(db.hashes) > 0 {
+		enc.Hashes = make(map[uint64]string, len(db.hashes))
+		for num, hash := range db.hashes {
+			enc.Hashes[num] = hash.Hex()
+		}
+	}
+	return json.Marshal(enc)
+}
+
+// UnmarshalJSON replaces the content of db with the encoding of MarshalJSON,
+// the journal is discarded and the decoded state is committed.
+func (db *MemoryDatabase) UnmarshalJSON(data []byte) error {
+	var dec jsonMemoryDatabase
+	if err := json.Unmarshal(data, &dec); err != nil {
+		return err
+	}
+	accounts := make(map[types.Address]*memoryAccount, len(dec.Accounts))
+	for addrString, acc := range dec.Accounts {
+		addr, err := types.HexToAddress(addrString)
+		if err != nil {
+			return fmt.Errorf("invalid address %q: %v", addrString, err)
+		}
+		account := newMemoryAccount()
+		for tokenString, balanceString := range acc.Balances {
+			tokenTypeId, err := types.HexToTokenTypeId(tokenString)
+			if err != nil {
+				return fmt.Errorf("invalid token type id %q: %v", tokenString, err)
+			}
+			balance, ok := new(big.Int).SetString(balanceString, 10)
+			if !ok {
+				return fmt.Errorf("invalid balance %q", balanceString)
+			}
+			account.balances[tokenTypeId] = balance
+		}
+		if acc.Code != "" {
+			code, err := hex.DecodeString(strings.TrimPrefix(acc.Code, "0x"))
+			if err != nil {
+				return fmt.Errorf("invalid code of %v: %v", addrString, err)
+			}
+			account.code, account.codeHash = code, types.DataHash(code)
+		}
+		for locString, valueString := range acc.Storage {
+			loc, err := types.HexToHash(strings.TrimPrefix(locString, "0x"))
+			if err != nil {
+				return fmt.Errorf("invalid storage location %q: %v", locString, err)
+			}
+			value, err := types.HexToHash(strings.TrimPrefix(valueString, "0x"))
+			if err != nil {
+				return fmt.Errorf("invalid storage value %q: %v", valueString, err)
+			}
+			if value != (types.Hash{}) {
+				account.storage[loc] = value
+			}
+		}
+		accounts[addr] = account
+	}
+	hashes := make(map[uint64]types.Hash, len(dec.Hashes))
+	for num, hashString := range dec.Hashes {
+		hash, err := types.HexToHash(strings.TrimPrefix(hashString, "0x"))
+		if err != nil {
+			return fmt.Errorf("invalid hash %q: %v", hashString, err)
+		}
+		hashes[num] = hash
+	}
+	db.accounts, db.hashes, db.journal, db.origins = accounts, hashes, nil, nil
+	return nil
+}
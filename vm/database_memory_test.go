@@ -0,0 +1,173 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/vitelabs/go-vite/common/types"
+	"math/big"
+	"testing"
+)
+
+func testAddress(b byte) types.Address {
+	addr, _ := types.BytesToAddress(bytes.Repeat([]byte{b}, types.AddressSize))
+	return addr
+}
+
+func testHash(n int64) types.Hash {
+	hash, _ := types.BigToHash(big.NewInt(n))
+	return hash
+}
+
+func TestMemoryDatabase_RevertToSnapShot(t *testing.T) {
+	db := NewMemoryDatabase()
+	addr, tokenTypeId := testAddress(1), types.CreateTokenTypeId()
+	db.AddBalance(addr, tokenTypeId, big.NewInt(100))
+	db.SetState(addr, testHash(1), testHash(1))
+
+	revertId := db.Snapshot()
+	db.SubBalance(addr, tokenTypeId, big.NewInt(30))
+	db.SetState(addr, testHash(1), types.Hash{})
+	db.SetState(addr, testHash(2), testHash(2))
+	db.SetContractCode(addr, []byte{1, 2, 3})
+	db.CreateAccount(testAddress(2))
+	db.DeleteAccount(addr)
+	if db.IsExistAddress(addr) || !db.IsExistAddress(testAddress(2)) {
+		t.Fatalf("unexpected accounts before revert")
+	}
+
+	db.RevertToSnapShot(revertId)
+	if !db.IsExistAddress(addr) || db.IsExistAddress(testAddress(2)) {
+		t.Fatalf("unexpected accounts after revert")
+	}
+	if balance := db.GetBalance(addr, tokenTypeId); balance.Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("expected balance 100, got %v", balance)
+	}
+	if db.GetState(addr, testHash(1)) != testHash(1) || db.GetState(addr, testHash(2)) != (types.Hash{}) {
+		t.Fatalf("unexpected storage after revert, %v", db.GetStatesString(addr))
+	}
+	if db.GetContractCodeSize(addr) != 0 || db.GetContractCodeHash(addr) != (types.Hash{}) {
+		t.Fatalf("unexpected code after revert")
+	}
+}
+
+func TestMemoryDatabase_GetCommittedState(t *testing.T) {
+	db := NewMemoryDatabase()
+	addr := testAddress(1)
+	db.SetState(addr, testHash(1), testHash(1))
+	db.SetState(addr, testHash(2), testHash(2))
+	if db.GetCommittedState(addr, testHash(1)) != (types.Hash{}) {
+		t.Fatalf("expected state set before the first commit not to be committed")
+	}
+	db.Commit()
+
+	revertId := db.Snapshot()
+	db.SetState(addr, testHash(1), testHash(3))
+	db.SetState(addr, testHash(1), testHash(4))
+	db.SetState(addr, testHash(3), testHash(3))
+	db.RevertToSnapShot(revertId)
+	db.SetState(addr, testHash(1), testHash(5))
+	db.DeleteAccount(addr)
+	db.SetState(addr, testHash(2), testHash(6))
+	for i, expected := range []types.Hash{{}, testHash(1), testHash(2), {}} {
+		if committed := db.GetCommittedState(addr, testHash(int64(i))); committed != expected {
+			t.Errorf("slot %v: expected committed %v, got %v", i, expected, committed)
+		}
+	}
+
+	db.Commit()
+	if db.GetCommittedState(addr, testHash(1)) != (types.Hash{}) || db.GetCommittedState(addr, testHash(2)) != testHash(6) {
+		t.Fatalf("expected the current state to be committed, got %v", db.GetStatesString(addr))
+	}
+}
+
+func TestMemoryDatabase_JSON(t *testing.T) {
+	db := NewMemoryDatabase()
+	addr, tokenTypeId := testAddress(1), types.CreateTokenTypeId()
+	db.AddBalance(addr, tokenTypeId, big.NewInt(100))
+	db.SetContractCode(addr, []byte{1, 2, 3})
+	db.SetState(addr, testHash(1), testHash(2))
+	db.CreateAccount(testAddress(2))
+	db.SetHash(5, testHash(5))
+
+	data, err := json.Marshal(db)
+	if err != nil {
+		t.Fatalf("marshal failed, %v", err)
+	}
+	decoded := NewMemoryDatabase()
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("unmarshal failed, %v", err)
+	}
+	if decoded.GetBalance(addr, tokenTypeId).Cmp(big.NewInt(100)) != 0 || !bytes.Equal(decoded.GetContractCode(addr), []byte{1, 2, 3}) ||
+		decoded.GetContractCodeHash(addr) != types.DataHash([]byte{1, 2, 3}) || decoded.GetState(addr, testHash(1)) != testHash(2) ||
+		!decoded.IsExistAddress(testAddress(2)) || decoded.GetHash(5) != testHash(5) {
+		t.Fatalf("unexpected database after round trip, %s", data)
+	}
+	if again, _ := json.Marshal(decoded); !bytes.Equal(again, data) {
+		t.Fatalf("expected %s, got %s", data, again)
+	}
+
+	if err := json.Unmarshal([]byte(`{"accounts": {"vite_00": {}}}`), decoded); err == nil {
+		t.Fatalf("expected an error for an invalid address")
+	}
+}
+
+func TestVM_CallRevert(t *testing.T) {
+	db := NewMemoryDatabase()
+	from, to, tokenTypeId := testAddress(1), testAddress(2), types.CreateTokenTypeId()
+	// sstore(0, 1); revert(0, 0)
+	code, _ := hex.DecodeString("600160005560006000fd")
+	db.SetContractCode(to, code)
+
+	vm := NewVM(Transaction{From: from, To: to, Depth: 1, TxType: TxTypeReceive, TokenTypeId: tokenTypeId, Amount: big.NewInt(10)})
+	vm.StateDb = db
+	result, err := vm.Call()
+	if err != ErrExecutionReverted {
+		t.Fatalf("expected %v, got %v", ErrExecutionReverted, err)
+	}
+	txs := result.Txs
+	if db.GetState(to, testHash(0)) != (types.Hash{}) {
+		t.Fatalf("expected storage to be reverted, got %v", db.GetStatesString(to))
+	}
+	if len(txs) != 1 || txs[0].To != from || txs[0].Amount.Cmp(big.NewInt(10)) != 0 {
+		t.Fatalf("expected a refund transaction, got %v", txs)
+	}
+}
+
+func TestVM_DelegateCallRevert(t *testing.T) {
+	db := NewMemoryDatabase()
+	from, to, lib := testAddress(1), testAddress(2), testAddress(3)
+	// sstore(0, 1); revert(0, 0)
+	libCode, _ := hex.DecodeString("600160005560006000fd")
+	db.SetContractCode(lib, libCode)
+	// pop(delegatecall(lib, 0, 0, 0, 0)); sstore(1, 2); stop
+	code, _ := hex.DecodeString("600060006000600073" + hex.EncodeToString(lib.Bytes()) + "f450600260015500")
+	db.SetContractCode(to, code)
+
+	vm := NewVM(Transaction{From: from, To: to, Depth: 1, TxType: TxTypeReceive, Amount: big.NewInt(0)})
+	vm.StateDb = db
+	if _, err := vm.Call(); err != nil {
+		t.Fatalf("call failed, %v", err)
+	}
+	if db.GetState(to, testHash(0)) != (types.Hash{}) || db.GetState(to, testHash(1)) != testHash(2) {
+		t.Fatalf("expected only the delegate call to be reverted, got %v", db.GetStatesString(to))
+	}
+}
+
+func TestVM_CreateRevert(t *testing.T) {
+	db := NewMemoryDatabase()
+	from := testAddress(1)
+	// sstore(0, 1); revert(0, 0)
+	code, _ := hex.DecodeString("600160005560006000fd")
+	tx := Transaction{From: from, Depth: 1, TxType: TxTypeReceive, Amount: big.NewInt(0), Data: code}
+	contractAddr := contractAddress(receiveCreateAddressTag, from, nil, types.DataHash(code).Bytes(), heightBytes(nil))
+
+	vm := NewVM(tx)
+	vm.StateDb = db
+	if _, err := vm.Create(); err != ErrExecutionReverted {
+		t.Fatalf("expected %v, got %v", ErrExecutionReverted, err)
+	}
+	if db.IsExistAddress(contractAddr) || db.GetState(contractAddr, testHash(0)) != (types.Hash{}) {
+		t.Fatalf("expected the contract not to be created")
+	}
+}
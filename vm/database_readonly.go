@@ -0,0 +1,38 @@
+package vm
+
+import (
+	"github.com/vitelabs/go-vite/common/types"
+	"math/big"
+)
+
+// readOnlyDatabase passes reads through to the wrapped Database and drops
+// every write, remembering that one was attempted so that the execution can be
+// failed with ErrWriteProtection afterwards.
+type readOnlyDatabase struct {
+	Database
+	err error
+}
+
+func (db *readOnlyDatabase) SubBalance(addr types.Address, tokenTypeId types.TokenTypeId, amount *big.Int) {
+	db.err = ErrWriteProtection
+}
+
+func (db *readOnlyDatabase) AddBalance(addr types.Address, tokenTypeId types.TokenTypeId, amount *big.Int) {
+	db.err = ErrWriteProtection
+}
+
+func (db *readOnlyDatabase) CreateAccount(addr types.Address) {
+	db.err = ErrWriteProtection
+}
+
+func (db *readOnlyDatabase) DeleteAccount(addr types.Address) {
+	db.err = ErrWriteProtection
+}
+
+func (db *readOnlyDatabase) SetContractCode(addr types.Address, code []byte) {
+	db.err = ErrWriteProtection
+}
+
+func (db *readOnlyDatabase) SetState(addr types.Address, loc types.Hash, value types.Hash) {
+	db.err = ErrWriteProtection
+}
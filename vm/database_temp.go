@@ -24,6 +24,9 @@ type Database interface {
 	GetContractCodeHash(addr types.Address) types.Hash
 
 	GetState(addr types.Address, loc types.Hash) types.Hash
+	// GetCommittedState returns the value of loc at the start of the
+	// transaction, unaffected by SetState during its execution.
+	GetCommittedState(addr types.Address, loc types.Hash) types.Hash
 	SetState(addr types.Address, loc types.Hash, value types.Hash)
 	GetStatesString(addr types.Address) string
 
@@ -39,6 +42,9 @@ func (db *testDatabase) SubBalance(addr types.Address, tokenTypeId types.TokenTy
 }
 func (db *testDatabase) AddBalance(addr types.Address, tokenTypeId types.TokenTypeId, amount *big.Int) {
 }
+func (db *testDatabase) GetCommittedState(addr types.Address, loc types.Hash) types.Hash {
+	return types.Hash{}
+}
 func (db *testDatabase) Snapshot() int                                                 { return 0 }
 func (db *testDatabase) RevertToSnapShot(revertId int)                                 {}
 func (db *testDatabase) IsExistAddress(addr types.Address) bool                        { return false }
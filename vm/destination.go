@@ -2,7 +2,6 @@ package vm
 
 import (
 	"github.com/vitelabs/go-vite/common/types"
-	"math/big"
 )
 
 type bitvec []byte
@@ -22,21 +21,28 @@ func (bits *bitvec) codeSegment(pos uint64) bool {
 	return ((*bits)[pos/8] & (0x80 >> (pos % 8))) == 0
 }
 
-// has checks whether code has a JUMPDEST at dest.
-func (d destinations) has(codehash types.Hash, code []byte, dest *big.Int) bool {
-	// PC cannot go beyond len(code) and certainly can't be bigger than 63bits.
+// has checks whether code has a JUMPDEST at dest. Code not analysed yet by
+// the contract is looked up in cache, unless its hash is unknown. The cache
+// is shared by every VM, so codehash must be the DataHash of code: a wrong
+// hash would hand the jump destinations of one code to another.
+func (d destinations) has(cache *JumpdestCache, codehash types.Hash, code []byte, dest *word) bool {
+	// PC cannot go beyond len(code) and certainly can't be bigger than 64bits.
 	// Don't bother checking for JUMPDEST in that case.
-	udest := dest.Uint64()
-	if dest.BitLen() >= 63 || udest >= uint64(len(code)) {
+	udest, overflow := dest.uint64WithOverflow()
+	if overflow || udest >= uint64(len(code)) {
 		return false
 	}
 
 	m, analysed := d[codehash]
 	if !analysed {
-		m = codeBitmap(code)
+		if cache != nil && codehash != (types.Hash{}) {
+			m = cache.bitmap(codehash, code)
+		} else {
+			m = codeBitmap(code)
+		}
 		d[codehash] = m
 	}
-	return opCode(code[udest]) == JUMPDEST && m.codeSegment(udest)
+	return OpCode(code[udest]) == JUMPDEST && m.codeSegment(udest)
 }
 
 // codeBitmap collects data locations in code.
@@ -46,7 +52,7 @@ func codeBitmap(code []byte) bitvec {
 	// bitvector outside the bounds of the actual code.
 	bits := make(bitvec, len(code)/8+1+4)
 	for pc := uint64(0); pc < uint64(len(code)); {
-		op := opCode(code[pc])
+		op := OpCode(code[pc])
 
 		if op >= PUSH1 && op <= PUSH32 {
 			numbits := op - PUSH1 + 1
@@ -0,0 +1,156 @@
+package vm
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Instruction is an instruction decoded from contract code.
+type Instruction struct {
+	Pc    uint64
+	Op    OpCode
+	Arg   []byte // Data pushed by a PUSH, shorter than the PUSH size if the code ends early
+	Valid bool   // Whether Op is part of the instruction set
+}
+
+// String renders the instruction as "pc: OPCODE 0xarg", pc in hex like jump
+// destinations, invalid opcodes marked as such.
+func (ins Instruction) String() string {
+	s := fmt.Sprintf("%04x: %v", ins.Pc, ins.Op)
+	if ins.Op.isPush() {
+		s += " " + hexBytes(ins.Arg)
+		if size := int(ins.Op - PUSH1 + 1); len(ins.Arg) < size {
+			s += fmt.Sprintf(" (truncated, %v of %v bytes)", len(ins.Arg), size)
+		}
+	}
+	if !ins.Valid {
+		s += " (invalid)"
+	}
+	return s
+}
+
+// Disassemble decodes code into instructions, checking opcodes against the
+// latest instruction set.
+func Disassemble(code []byte) []Instruction {
+	var instructions []Instruction
+	for pc := uint64(0); pc < uint64(len(code)); pc++ {
+		op := OpCode(code[pc])
+		ins := Instruction{Pc: pc, Op: op, Valid: simpleInstructionSet[op].valid}
+		if op.isPush() {
+			end := pc + 1 + uint64(op-PUSH1+1)
+			if end > uint64(len(code)) {
+				end = uint64(len(code))
+			}
+			ins.Arg = code[pc+1 : end]
+			pc = end - 1
+		}
+		instructions = append(instructions, ins)
+	}
+	return instructions
+}
+
+// CodeSections are the parts of contract code as emitted by Solidity.
+type CodeSections struct {
+	Init     []byte // Creation code, empty if code is runtime code
+	Runtime  []byte // Code deployed by Init, without Metadata
+	Metadata []byte // CBOR encoded metadata appended to Runtime, like a165627a7a72305820<bzzr hash>0029
+	Args     []byte // Data after the runtime code in creation code, usually constructor arguments
+}
+
+// SplitCode splits creation or runtime code into its sections. The runtime
+// code is located through the first CODECOPY of the creation code, whose
+// arguments Solidity pushes right before it; code without such a CODECOPY is
+// taken to be runtime code.
+func SplitCode(code []byte) CodeSections {
+	var sections CodeSections
+	if offset, size, ok := findRuntimeCopy(code); ok {
+		sections.Init = code[:offset]
+		sections.Runtime = code[offset : offset+size]
+		sections.Args = code[offset+size:]
+	} else {
+		sections.Runtime = code
+	}
+	// the metadata ends with its length as two big-endian bytes and starts
+	// with a CBOR map
+	if n := len(sections.Runtime); n >= 2 {
+		length := int(sections.Runtime[n-2])<<8 | int(sections.Runtime[n-1])
+		if length > 0 && length+2 <= n {
+			if start := n - 2 - length; sections.Runtime[start] >= 0xa1 && sections.Runtime[start] <= 0xb7 {
+				sections.Runtime, sections.Metadata = sections.Runtime[:start], sections.Runtime[start:]
+			}
+		}
+	}
+	return sections
+}
+
+// findRuntimeCopy returns the code range copied by the first CODECOPY whose
+// arguments are constants, skipping the copy of constructor arguments from
+// the end of the code. Only PUSH, DUP, SWAP and POP are followed, any other
+// instruction forgets the stack.
+func findRuntimeCopy(code []byte) (offset, size uint64, ok bool) {
+	type value struct {
+		n     uint64
+		known bool
+	}
+	var st []value
+	for _, ins := range Disassemble(code) {
+		switch {
+		case ins.Op.isPush():
+			var w word
+			n, overflow := w.setBytes(ins.Arg).uint64WithOverflow()
+			st = append(st, value{n, !overflow})
+		case ins.Op >= DUP1 && ins.Op <= DUP16:
+			if n := int(ins.Op-DUP1) + 1; n <= len(st) {
+				st = append(st, st[len(st)-n])
+			} else {
+				st = append(st, value{})
+			}
+		case ins.Op >= SWAP1 && ins.Op <= SWAP16:
+			if n := int(ins.Op-SWAP1) + 1; n < len(st) {
+				st[len(st)-1], st[len(st)-1-n] = st[len(st)-1-n], st[len(st)-1]
+			} else {
+				st = st[:0]
+			}
+		case ins.Op == POP && len(st) > 0:
+			st = st[:len(st)-1]
+		case ins.Op == CODECOPY:
+			if len(st) >= 3 {
+				offset, size := st[len(st)-2], st[len(st)-3]
+				if offset.known && size.known && offset.n > 0 && offset.n <= uint64(len(code)) && size.n <= uint64(len(code))-offset.n {
+					return offset.n, size.n, true
+				}
+			}
+			st = st[:0]
+		default:
+			st = st[:0]
+		}
+	}
+	return 0, 0, false
+}
+
+// DisassembleString renders code one instruction per line, split into
+// sections by SplitCode. Each section starts at pc 0, the runtime code is
+// deployed on its own.
+func DisassembleString(code []byte) string {
+	var b strings.Builder
+	sections := SplitCode(code)
+	writeCode := func(name string, code []byte) {
+		if len(code) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "%v:\n", name)
+		for _, ins := range Disassemble(code) {
+			fmt.Fprintf(&b, "%v\n", ins)
+		}
+	}
+	writeCode("init code", sections.Init)
+	writeCode("runtime code", sections.Runtime)
+	if len(sections.Metadata) > 0 {
+		fmt.Fprintf(&b, "metadata:\n%v\n", hex.EncodeToString(sections.Metadata))
+	}
+	if len(sections.Args) > 0 {
+		fmt.Fprintf(&b, "arguments:\n%v\n", hex.EncodeToString(sections.Args))
+	}
+	return b.String()
+}
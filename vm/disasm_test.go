@@ -0,0 +1,47 @@
+package vm
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestDisassemble(t *testing.T) {
+	code, _ := hex.DecodeString("6080fe5b61ab")
+	expected := []string{
+		"0000: PUSH1 0x80",
+		"0002: Missing opcode 0xfe (invalid)",
+		"0003: JUMPDEST",
+		"0004: PUSH2 0xab (truncated, 1 of 2 bytes)",
+	}
+	instructions := Disassemble(code)
+	if len(instructions) != len(expected) {
+		t.Fatalf("expected %v instructions, got %v", len(expected), instructions)
+	}
+	for i, ins := range instructions {
+		if ins.String() != expected[i] {
+			t.Errorf("instruction %v: expected %q, got %q", i, expected[i], ins.String())
+		}
+	}
+}
+
+func TestSplitCode(t *testing.T) {
+	// creation code of TestVM_CreateSend
+	code, _ := hex.DecodeString("608060405260008055348015601357600080fd5b5060358060216000396000f3006080604052600080fd00a165627a7a723058207c31c74808fe0f95820eb3c48eac8e3e10ef27058dc6ca159b547fccde9290790029")
+	tests := []struct {
+		code                          []byte
+		init, runtime, metadata, args string
+	}{
+		{code, hex.EncodeToString(code[:0x21]), "6080604052600080fd00", hex.EncodeToString(code[0x21+10:]), ""},
+		{append(code, 1, 2), hex.EncodeToString(code[:0x21]), "6080604052600080fd00", hex.EncodeToString(code[0x21+10:]), "0102"},
+		{code[0x21:], "", "6080604052600080fd00", hex.EncodeToString(code[0x21+10:]), ""},
+		// codecopy(0, 5, 32) reads past the end of the code
+		{[]byte{0x60, 0x20, 0x60, 0x05, 0x60, 0x00, 0x39, 0x00}, "", "6020600560003900", "", ""},
+	}
+	for i, test := range tests {
+		sections := SplitCode(test.code)
+		if hex.EncodeToString(sections.Init) != test.init || hex.EncodeToString(sections.Runtime) != test.runtime ||
+			hex.EncodeToString(sections.Metadata) != test.metadata || hex.EncodeToString(sections.Args) != test.args {
+			t.Errorf("test %v: unexpected sections %x %x %x %x", i, sections.Init, sections.Runtime, sections.Metadata, sections.Args)
+		}
+	}
+}
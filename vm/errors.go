@@ -1,6 +1,11 @@
 package vm
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"github.com/vitelabs/go-vite/common/types"
+	"math/big"
+)
 
 var (
 	ErrOutOfQuota                  = errors.New("out of quota")
@@ -8,9 +13,32 @@ var (
 	ErrInsufficientBalance         = errors.New("insufficient balance for transfer")
 	ErrContractAddressCreationFail = errors.New("contract address collision")
 	ErrExecutionReverted           = errors.New("execution reverted")
+	ErrWriteProtection             = errors.New("write protection")
+	ErrVMInternal                  = errors.New("vm internal error")
+	ErrExecutionAborted            = errors.New("execution aborted")
+	ErrInvalidCreateSend           = errors.New("contract creation send does not match its address")
 )
 
 var (
 	errGasUintOverflow       = errors.New("gas uint64 overflow")
 	errReturnDataOutOfBounds = errors.New("evm: return data out of bounds")
 )
+
+// InternalError is returned instead of a panic raised while executing an
+// opcode, it wraps ErrVMInternal. The execution fails like on any other
+// error and its state changes are reverted.
+type InternalError struct {
+	Op      OpCode
+	Pc      uint64
+	Address types.Address // Address of the contract executed
+	Stack   []*big.Int    // Stack at the time of the panic
+	Panic   interface{}   // Value passed to panic
+}
+
+func (e *InternalError) Error() string {
+	return fmt.Sprintf("%v: %v at pc %v of %v: %v", ErrVMInternal, e.Op, e.Pc, e.Address, e.Panic)
+}
+
+func (e *InternalError) Unwrap() error {
+	return ErrVMInternal
+}
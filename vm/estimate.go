@@ -0,0 +1,77 @@
+package vm
+
+import (
+	"github.com/vitelabs/go-vite/common/types"
+)
+
+// QuotaEstimate is the result of EstimateQuota.
+type QuotaEstimate struct {
+	Quota  uint64           // Minimum quota the transaction runs with without running out of quota
+	Result *ExecutionResult // Result of executing the transaction with Quota
+}
+
+// EstimateQuota returns the minimum quota tx needs to not fail with
+// ErrOutOfQuota under the rules config has in effect at tx.SnapshotHeight,
+// searching up to DefaultQuotaParams.MaxQuota. Transactions to an empty
+// address and sends emitted by CREATE and CREATE2 run VM.Create, everything
+// else VM.Call. Every execution is reverted, db is unchanged afterwards.
+//
+// The estimate is the quota consumed at the peak of the execution, which is
+// more than the QuotaUsed charged in the end when SSTORE refunds apply.
+func EstimateQuota(tx Transaction, db Database, config *ChainConfig) (*QuotaEstimate, error) {
+	create := tx.To == (types.Address{}) || tx.TxType == TxTypeSendCreate
+	cost, err := intrinsicGasCost(config.quotaSchedule(tx.SnapshotHeight), tx.Data, create)
+	if err != nil {
+		return nil, err
+	}
+
+	// execute returns the result of tx with quota and whether a DELEGATECALL
+	// or STATICCALL ran out of quota
+	execute := func(quota uint64) (*QuotaEstimate, bool) {
+		revertId := db.Snapshot()
+		defer db.RevertToSnapShot(revertId)
+
+		vm := NewVMWithChainConfig(tx, config)
+		vm.StateDb = db
+		vm.QuotaProvider = FixedQuotaProvider(quota)
+		estimate := &QuotaEstimate{Quota: quota}
+		if create {
+			estimate.Result, _ = vm.Create()
+		} else {
+			estimate.Result, _ = vm.Call()
+		}
+		return estimate, vm.callOutOfQuota
+	}
+
+	hi := DefaultQuotaParams.MaxQuota
+	result, callOutOfQuota := execute(hi)
+	if result.Result.Err == ErrOutOfQuota {
+		return nil, ErrOutOfQuota
+	}
+
+	// lo is not enough and hi is. A DELEGATECALL or STATICCALL running out of
+	// quota does not fail the transaction, the caller goes on with a failed
+	// call, so less quota is only enough if the outcome is the one with hi.
+	outcome := result.Result
+	enough := func(r *QuotaEstimate, outOfQuota bool) bool {
+		return r.Result.Err != ErrOutOfQuota && outOfQuota == callOutOfQuota &&
+			r.Result.Failure == outcome.Failure && errorString(r.Result.Err) == errorString(outcome.Err)
+	}
+	lo := cost - 1
+	for lo+1 < hi {
+		mid := lo + (hi-lo)/2
+		if r, outOfQuota := execute(mid); enough(r, outOfQuota) {
+			hi, result = mid, r
+		} else {
+			lo = mid
+		}
+	}
+	return result, nil
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
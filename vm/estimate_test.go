@@ -0,0 +1,95 @@
+package vm
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/vitelabs/go-vite/common/types"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestEstimateQuota(t *testing.T) {
+	db := NewMemoryDatabase()
+	from, to, loop := testAddress(1), testAddress(2), testAddress(3)
+	// sstore(0, 1); sstore(0, 0); stop
+	code, _ := hex.DecodeString("600160005560006000550000")
+	db.SetContractCode(to, code)
+	// jumpdest; jump(0)
+	loopCode, _ := hex.DecodeString("5b600056")
+	db.SetContractCode(loop, loopCode)
+	snapshot := db.Snapshot()
+
+	tx := Transaction{From: from, To: to, Depth: 1, TxType: TxTypeReceive, Amount: big.NewInt(10)}
+	estimate, err := EstimateQuota(tx, db, DefaultChainConfig)
+	if err != nil {
+		t.Fatalf("estimate failed, %v", err)
+	}
+	// the refund of clearing the slot is only paid back in the end
+	if estimate.Quota != 41212 || estimate.Result.QuotaUsed == estimate.Quota || estimate.Result.Err != nil {
+		t.Fatalf("unexpected estimate %+v", estimate)
+	}
+	if db.Snapshot() != snapshot || db.GetBalance(to, types.TokenTypeId{}).Sign() != 0 {
+		t.Fatalf("expected the database to be unchanged")
+	}
+
+	vm := NewVM(tx)
+	vm.StateDb = db
+	vm.QuotaProvider = FixedQuotaProvider(estimate.Quota - 1)
+	if _, err := vm.Call(); err != ErrOutOfQuota {
+		t.Fatalf("expected %v with less quota than estimated, got %v", ErrOutOfQuota, err)
+	}
+	db.RevertToSnapShot(snapshot)
+
+	create := Transaction{From: from, Depth: 1, TxType: TxTypeSend, Amount: big.NewInt(0), Data: code}
+	if estimate, err := EstimateQuota(create, db, DefaultChainConfig); err != nil || estimate.Quota != 53000+7*68+5*4 || estimate.Result.ContractAddress != (types.Address{}) {
+		t.Fatalf("unexpected create estimate %+v %v", estimate, err)
+	}
+	s := *DefaultQuotaSchedule()
+	s.TxContractCreation = 1000
+	repriced := &ChainConfig{LeafHeight: big.NewInt(0), QuotaSchedules: []QuotaScheduleUpgrade{{big.NewInt(0), &s}}}
+	if estimate, err := EstimateQuota(create, db, repriced); err != nil || estimate.Quota != 1000+7*68+5*4 {
+		t.Fatalf("unexpected create estimate with a repriced schedule %+v %v", estimate, err)
+	}
+
+	if _, err := EstimateQuota(Transaction{From: from, To: loop, Depth: 1, TxType: TxTypeReceive, Amount: big.NewInt(0)}, db, DefaultChainConfig); err != ErrOutOfQuota {
+		t.Fatalf("expected %v, got %v", ErrOutOfQuota, err)
+	}
+}
+
+func TestEstimateQuota_CallOutOfQuota(t *testing.T) {
+	db := NewMemoryDatabase()
+	from, to, callee := testAddress(1), testAddress(2), testAddress(3)
+	// sstore(i, 1) for i < 10
+	var src strings.Builder
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(&src, "PUSH 1 PUSH %v SSTORE ", i)
+	}
+	calleeCode, err := Assemble(src.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetContractCode(callee, calleeCode)
+	// sstore(100, delegatecall(callee)), with too little quota for the callee
+	// the failed call is cheaper than the successful one
+	code, err := Assemble("PUSH 0 PUSH 0 PUSH 0 PUSH 0 PUSH 0x" + hex.EncodeToString(callee.Bytes()) + " DELEGATECALL PUSH 100 SSTORE STOP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetContractCode(to, code)
+
+	tx := Transaction{From: from, To: to, Depth: 1, TxType: TxTypeReceive, Amount: big.NewInt(0)}
+	estimate, err := EstimateQuota(tx, db, DefaultChainConfig)
+	if err != nil {
+		t.Fatalf("estimate failed, %v", err)
+	}
+	vm := NewVM(tx)
+	vm.StateDb = db
+	vm.QuotaProvider = FixedQuotaProvider(estimate.Quota)
+	if _, err := vm.Call(); err != nil || vm.callOutOfQuota {
+		t.Fatalf("expected the delegate call to succeed with the estimate %v, got %v", estimate.Quota, err)
+	}
+	if db.GetState(to, testHash(100)) != testHash(1) {
+		t.Fatalf("expected the delegate call to succeed with the estimate %v, got %v", estimate.Quota, db.GetStatesString(to))
+	}
+}
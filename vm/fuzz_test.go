@@ -0,0 +1,139 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/vitelabs/go-vite/common/types"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// fuzzSeeds is code taken from the other tests.
+var fuzzSeeds = []string{
+	"6001600201602080919052602090F3",
+	"608060405260008055348015601357600080fd5b5060358060216000396000f3006080604052600080fd00a165627a7a723058207c31c74808fe0f95820eb3c48eac8e3e10ef27058dc6ca159b547fccde9290790029",
+	"602a60005260206000600a60056007f100",
+	"602a60005260206000600a60056007f160006000fd",
+	"6000600060006000f06000600060006000f060016000600060006000f500",
+	"600160005560006000fd",
+	"600760006000a160006000550000",
+	"600760006000a160ab6000526001601ffd",
+	"6004406000524260205260406000f3",
+	"60006000600060006000600a5af4",
+	"5b600056",
+	"fe",
+}
+
+const fuzzQuota = 200000
+
+// monotonicQuotaTracer fails the test if the quota left ever increases.
+type monotonicQuotaTracer struct {
+	t     *testing.T
+	quota uint64
+}
+
+func (m *monotonicQuotaTracer) CaptureStart(from types.Address, to types.Address, create bool, input []byte, quota uint64, amount *big.Int) {
+	m.quota = quota
+}
+
+func (m *monotonicQuotaTracer) CaptureState(vm *VM, pc uint64, op OpCode, quota, cost uint64, memory []byte, stack []*big.Int, contractAddr types.Address, depth int, err error) {
+	if quota > m.quota {
+		m.t.Fatalf("quota increased from %v to %v at pc %v (%v)", m.quota, quota, pc, op)
+	}
+	m.quota = quota
+}
+
+func (m *monotonicQuotaTracer) CaptureFault(vm *VM, pc uint64, op OpCode, quota, cost uint64, memory []byte, stack []*big.Int, contractAddr types.Address, depth int, err error) {
+}
+
+func (m *monotonicQuotaTracer) CaptureEnd(output []byte, quotaUsed uint64, d time.Duration, err error) {
+}
+
+// FuzzRun runs random code in a VM with an empty environment, transaction
+// heights and timestamp are nil as they are for transactions built in code.
+func FuzzRun(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		code, _ := hex.DecodeString(seed)
+		f.Add(code, []byte{})
+	}
+	f.Fuzz(func(t *testing.T, code, input []byte) {
+		vm := NewVM(Transaction{Depth: 1, Amount: new(big.Int), Data: input})
+		vm.StateDb = NewMemoryDatabase()
+		vm.Tracer = &monotonicQuotaTracer{t: t, quota: fuzzQuota}
+		vm.quotaLeft = fuzzQuota
+		c := newContract(testAddress(1), testAddress(2), types.TokenTypeId{}, new(big.Int), input)
+		c.setCallCode(testAddress(2), types.DataHash(code), code)
+		if _, err := run(vm, c); errors.Is(err, ErrVMInternal) {
+			t.Fatal(err)
+		}
+		if vm.quotaLeft > fuzzQuota {
+			t.Fatalf("quota left %v exceeds the initial quota", vm.quotaLeft)
+		}
+	})
+}
+
+// FuzzCall calls random code holding a balance and storage. A failed call
+// must leave the state as it was, except for the amount received unless the
+// call ran out of quota.
+func FuzzCall(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		code, _ := hex.DecodeString(seed)
+		f.Add(code, []byte{}, uint64(0), []byte{1})
+		f.Add(code, []byte{1, 2, 3}, uint64(10), []byte{})
+	}
+	f.Fuzz(func(t *testing.T, code, input []byte, amount uint64, value []byte) {
+		from, to, tokenTypeId := testAddress(1), testAddress(2), types.CreateTokenTypeId()
+		db := NewMemoryDatabase()
+		db.SetContractCode(to, code)
+		db.AddBalance(to, tokenTypeId, big.NewInt(100))
+		db.SetState(to, testHash(0), testHash(1))
+		if hash, err := types.BytesToHash(leftPadBytes(value, 32)); err == nil {
+			db.SetState(to, testHash(1), hash)
+		}
+		db.SetHash(1, testHash(7))
+		db.Commit()
+		pre, err := json.Marshal(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tx := Transaction{From: from, To: to, TxType: TxTypeReceive, TokenTypeId: tokenTypeId, Amount: new(big.Int).SetUint64(amount), Data: input, Depth: 1,
+			AccountHeight: big.NewInt(2), SnapshotHeight: big.NewInt(1), SnapshotTimestamp: big.NewInt(1)}
+		vm := NewVM(tx)
+		vm.StateDb = db
+		vm.QuotaProvider = FixedQuotaProvider(fuzzQuota)
+		vm.Tracer = &monotonicQuotaTracer{t: t}
+		result, err := vm.Call()
+		if err != result.Err {
+			t.Fatalf("expected error %v in the result, got %v", err, result.Err)
+		}
+		if err == nil {
+			return
+		}
+		if errors.Is(err, ErrVMInternal) {
+			t.Fatal(err)
+		}
+
+		expected := NewMemoryDatabase()
+		json.Unmarshal(pre, expected)
+		if err != ErrOutOfQuota && amount > 0 {
+			expected.AddBalance(to, tokenTypeId, tx.Amount)
+		}
+		want, _ := json.Marshal(expected)
+		got, _ := json.Marshal(db)
+		if !bytes.Equal(want, got) {
+			t.Fatalf("state changed by a failed call (%v):\nexpected %s\ngot      %s", err, want, got)
+		}
+		if len(result.Logs) != 0 {
+			t.Fatalf("failed call emitted logs %v", result.Logs)
+		}
+		for _, tx := range result.Txs {
+			if tx.To != from || tx.Amount.Cmp(new(big.Int).SetUint64(amount)) != 0 {
+				t.Fatalf("failed call emitted %+v, expected only a refund", tx)
+			}
+		}
+	})
+}
@@ -6,7 +6,7 @@ import (
 
 // memoryGasCosts calculates the quadratic gas for memory expansion. It does so
 // only for the memory region that is expanded, not the total memory.
-func memoryGasCost(mem *memory, newMemSize uint64) (uint64, error) {
+func memoryGasCost(s *QuotaSchedule, mem *memory, newMemSize uint64) (uint64, error) {
 
 	if newMemSize == 0 {
 		return 0, nil
@@ -27,8 +27,8 @@ func memoryGasCost(mem *memory, newMemSize uint64) (uint64, error) {
 
 	if newMemSize > uint64(mem.len()) {
 		square := newMemSizeWords * newMemSizeWords
-		linCoef := newMemSizeWords * memoryGas
-		quadCoef := square / quadCoeffDiv
+		linCoef := newMemSizeWords * s.Memory
+		quadCoef := square / s.QuadCoeffDiv
 		newTotalFee := linCoef + quadCoef
 
 		fee := newTotalFee - mem.lastGasCost
@@ -45,14 +45,36 @@ func constGasFunc(gas uint64) gasFunc {
 	}
 }
 
+// scheduleGasFunc returns a gasFunc charging the constant quota selects from
+// the quota schedule of the VM.
+func scheduleGasFunc(quota func(s *QuotaSchedule) uint64) gasFunc {
+	return func(vm *VM, contrac *contract, stack *stack, mem *memory, memorySize uint64) (uint64, error) {
+		return quota(vm.quotaSchedule), nil
+	}
+}
+
+var (
+	gasQuickStep   = scheduleGasFunc(func(s *QuotaSchedule) uint64 { return s.QuickStep })
+	gasFastestStep = scheduleGasFunc(func(s *QuotaSchedule) uint64 { return s.FastestStep })
+	gasFastStep    = scheduleGasFunc(func(s *QuotaSchedule) uint64 { return s.FastStep })
+	gasMidStep     = scheduleGasFunc(func(s *QuotaSchedule) uint64 { return s.MidStep })
+	gasSlowStep    = scheduleGasFunc(func(s *QuotaSchedule) uint64 { return s.SlowStep })
+	gasExtStep     = scheduleGasFunc(func(s *QuotaSchedule) uint64 { return s.ExtStep })
+	gasExtCodeSize = scheduleGasFunc(func(s *QuotaSchedule) uint64 { return s.ExtCodeSize })
+	gasExtCodeHash = scheduleGasFunc(func(s *QuotaSchedule) uint64 { return s.ExtCodeHash })
+	gasBalance     = scheduleGasFunc(func(s *QuotaSchedule) uint64 { return s.Balance })
+	gasSLoad       = scheduleGasFunc(func(s *QuotaSchedule) uint64 { return s.SLoad })
+	gasJumpdest    = scheduleGasFunc(func(s *QuotaSchedule) uint64 { return s.Jumpdest })
+)
+
 func gasExp(vm *VM, contract *contract, stack *stack, mem *memory, memorySize uint64) (uint64, error) {
-	expByteLen := uint64((stack.back(1).BitLen() + 7) / 8)
+	expByteLen := uint64((stack.back(1).bitLen() + 7) / 8)
 
-	var (
-		gas      = expByteLen * expByteGas // no overflow check required. Max is 256 * expByteGas gas
-		overflow bool
-	)
-	if gas, overflow = SafeAdd(gas, slowStepGas); overflow {
+	gas, overflow := SafeMul(expByteLen, vm.quotaSchedule.ExpByte)
+	if overflow {
+		return 0, errGasUintOverflow
+	}
+	if gas, overflow = SafeAdd(gas, vm.quotaSchedule.SlowStep); overflow {
 		return 0, errGasUintOverflow
 	}
 	return gas, nil
@@ -60,20 +82,20 @@ func gasExp(vm *VM, contract *contract, stack *stack, mem *memory, memorySize ui
 
 func gasBlake2b(vm *VM, contract *contract, stack *stack, mem *memory, memorySize uint64) (uint64, error) {
 	var overflow bool
-	gas, err := memoryGasCost(mem, memorySize)
+	gas, err := memoryGasCost(vm.quotaSchedule, mem, memorySize)
 	if err != nil {
 		return 0, err
 	}
 
-	if gas, overflow = SafeAdd(gas, blake2bGas); overflow {
+	if gas, overflow = SafeAdd(gas, vm.quotaSchedule.Blake2b); overflow {
 		return 0, errGasUintOverflow
 	}
 
-	wordGas, overflow := bigUint64(stack.back(1))
+	wordGas, overflow := stack.back(1).uint64WithOverflow()
 	if overflow {
 		return 0, errGasUintOverflow
 	}
-	if wordGas, overflow = SafeMul(toWordSize(wordGas), blake2bWordGas); overflow {
+	if wordGas, overflow = SafeMul(toWordSize(wordGas), vm.quotaSchedule.Blake2bWord); overflow {
 		return 0, errGasUintOverflow
 	}
 	if gas, overflow = SafeAdd(gas, wordGas); overflow {
@@ -83,22 +105,22 @@ func gasBlake2b(vm *VM, contract *contract, stack *stack, mem *memory, memorySiz
 }
 
 func gasCallDataCopy(vm *VM, contract *contract, stack *stack, mem *memory, memorySize uint64) (uint64, error) {
-	gas, err := memoryGasCost(mem, memorySize)
+	gas, err := memoryGasCost(vm.quotaSchedule, mem, memorySize)
 	if err != nil {
 		return 0, err
 	}
 
 	var overflow bool
-	if gas, overflow = SafeAdd(gas, fastestStepGas); overflow {
+	if gas, overflow = SafeAdd(gas, vm.quotaSchedule.FastestStep); overflow {
 		return 0, errGasUintOverflow
 	}
 
-	words, overflow := bigUint64(stack.back(2))
+	words, overflow := stack.back(2).uint64WithOverflow()
 	if overflow {
 		return 0, errGasUintOverflow
 	}
 
-	if words, overflow = SafeMul(toWordSize(words), copyGas); overflow {
+	if words, overflow = SafeMul(toWordSize(words), vm.quotaSchedule.Copy); overflow {
 		return 0, errGasUintOverflow
 	}
 
@@ -109,21 +131,21 @@ func gasCallDataCopy(vm *VM, contract *contract, stack *stack, mem *memory, memo
 }
 
 func gasCodeCopy(vm *VM, contract *contract, stack *stack, mem *memory, memorySize uint64) (uint64, error) {
-	gas, err := memoryGasCost(mem, memorySize)
+	gas, err := memoryGasCost(vm.quotaSchedule, mem, memorySize)
 	if err != nil {
 		return 0, err
 	}
 
 	var overflow bool
-	if gas, overflow = SafeAdd(gas, fastestStepGas); overflow {
+	if gas, overflow = SafeAdd(gas, vm.quotaSchedule.FastestStep); overflow {
 		return 0, errGasUintOverflow
 	}
 
-	wordGas, overflow := bigUint64(stack.back(2))
+	wordGas, overflow := stack.back(2).uint64WithOverflow()
 	if overflow {
 		return 0, errGasUintOverflow
 	}
-	if wordGas, overflow = SafeMul(toWordSize(wordGas), copyGas); overflow {
+	if wordGas, overflow = SafeMul(toWordSize(wordGas), vm.quotaSchedule.Copy); overflow {
 		return 0, errGasUintOverflow
 	}
 	if gas, overflow = SafeAdd(gas, wordGas); overflow {
@@ -133,22 +155,22 @@ func gasCodeCopy(vm *VM, contract *contract, stack *stack, mem *memory, memorySi
 }
 
 func gasExtCodeCopy(vm *VM, contract *contract, stack *stack, mem *memory, memorySize uint64) (uint64, error) {
-	gas, err := memoryGasCost(mem, memorySize)
+	gas, err := memoryGasCost(vm.quotaSchedule, mem, memorySize)
 	if err != nil {
 		return 0, err
 	}
 
 	var overflow bool
-	if gas, overflow = SafeAdd(gas, extCodeCopyGas); overflow {
+	if gas, overflow = SafeAdd(gas, vm.quotaSchedule.ExtCodeCopy); overflow {
 		return 0, errGasUintOverflow
 	}
 
-	wordGas, overflow := bigUint64(stack.back(3))
+	wordGas, overflow := stack.back(3).uint64WithOverflow()
 	if overflow {
 		return 0, errGasUintOverflow
 	}
 
-	if wordGas, overflow = SafeMul(toWordSize(wordGas), copyGas); overflow {
+	if wordGas, overflow = SafeMul(toWordSize(wordGas), vm.quotaSchedule.Copy); overflow {
 		return 0, errGasUintOverflow
 	}
 
@@ -159,22 +181,22 @@ func gasExtCodeCopy(vm *VM, contract *contract, stack *stack, mem *memory, memor
 }
 
 func gasReturnDataCopy(vm *VM, contract *contract, stack *stack, mem *memory, memorySize uint64) (uint64, error) {
-	gas, err := memoryGasCost(mem, memorySize)
+	gas, err := memoryGasCost(vm.quotaSchedule, mem, memorySize)
 	if err != nil {
 		return 0, err
 	}
 
 	var overflow bool
-	if gas, overflow = SafeAdd(gas, fastestStepGas); overflow {
+	if gas, overflow = SafeAdd(gas, vm.quotaSchedule.FastestStep); overflow {
 		return 0, errGasUintOverflow
 	}
 
-	words, overflow := bigUint64(stack.back(2))
+	words, overflow := stack.back(2).uint64WithOverflow()
 	if overflow {
 		return 0, errGasUintOverflow
 	}
 
-	if words, overflow = SafeMul(toWordSize(words), copyGas); overflow {
+	if words, overflow = SafeMul(toWordSize(words), vm.quotaSchedule.Copy); overflow {
 		return 0, errGasUintOverflow
 	}
 
@@ -186,11 +208,11 @@ func gasReturnDataCopy(vm *VM, contract *contract, stack *stack, mem *memory, me
 
 func gasMLoad(vm *VM, contract *contract, stack *stack, mem *memory, memorySize uint64) (uint64, error) {
 	var overflow bool
-	gas, err := memoryGasCost(mem, memorySize)
+	gas, err := memoryGasCost(vm.quotaSchedule, mem, memorySize)
 	if err != nil {
 		return 0, errGasUintOverflow
 	}
-	if gas, overflow = SafeAdd(gas, fastestStepGas); overflow {
+	if gas, overflow = SafeAdd(gas, vm.quotaSchedule.FastestStep); overflow {
 		return 0, errGasUintOverflow
 	}
 	return gas, nil
@@ -198,11 +220,11 @@ func gasMLoad(vm *VM, contract *contract, stack *stack, mem *memory, memorySize
 
 func gasMStore(vm *VM, contract *contract, stack *stack, mem *memory, memorySize uint64) (uint64, error) {
 	var overflow bool
-	gas, err := memoryGasCost(mem, memorySize)
+	gas, err := memoryGasCost(vm.quotaSchedule, mem, memorySize)
 	if err != nil {
 		return 0, errGasUintOverflow
 	}
-	if gas, overflow = SafeAdd(gas, fastestStepGas); overflow {
+	if gas, overflow = SafeAdd(gas, vm.quotaSchedule.FastestStep); overflow {
 		return 0, errGasUintOverflow
 	}
 	return gas, nil
@@ -210,65 +232,97 @@ func gasMStore(vm *VM, contract *contract, stack *stack, mem *memory, memorySize
 
 func gasMStore8(vm *VM, contract *contract, stack *stack, mem *memory, memorySize uint64) (uint64, error) {
 	var overflow bool
-	gas, err := memoryGasCost(mem, memorySize)
+	gas, err := memoryGasCost(vm.quotaSchedule, mem, memorySize)
 	if err != nil {
 		return 0, errGasUintOverflow
 	}
-	if gas, overflow = SafeAdd(gas, fastestStepGas); overflow {
+	if gas, overflow = SafeAdd(gas, vm.quotaSchedule.FastestStep); overflow {
 		return 0, errGasUintOverflow
 	}
 	return gas, nil
 }
 
+// gasSStore meters SSTORE by the net change of the slot in the transaction.
+// Only the first change of a slot from its committed value pays SStoreSet or
+// SStoreReset, later changes and writes of the current value pay
+// SStoreDirty. Clearing a slot non-zero at the start of the transaction is
+// refunded SStoreRefund, taken back if the slot is set again, and restoring
+// the committed value refunds what the first change paid beyond SStoreDirty.
 func gasSStore(vm *VM, contract *contract, stack *stack, mem *memory, memorySize uint64) (uint64, error) {
 	var (
-		y    = stack.back(1)
-		x, _ = types.BigToHash(stack.back(0))
-		val  = vm.StateDb.GetState(contract.address, x)
+		s       = vm.quotaSchedule
+		loc     = wordToHash(stack.back(0))
+		value   = wordToHash(stack.back(1))
+		current = vm.StateDb.GetState(contract.address, loc)
+		zero    types.Hash
 	)
-	if val == (types.Hash{}) && y.Sign() != 0 {
-		return sstoreSetGas, nil
-	} else if val != (types.Hash{}) && y.Sign() == 0 {
-		vm.quotaReturn = vm.quotaReturn + sstoreRefundGas
-		return sstoreClearGas, nil
-	} else {
-		return sstoreResetGas, nil
+	if current == value {
+		return s.SStoreDirty, nil
+	}
+	original := vm.StateDb.GetCommittedState(contract.address, loc)
+	if original == current {
+		if original == zero {
+			return s.SStoreSet, nil
+		}
+		if value == zero {
+			vm.quotaReturn += s.SStoreRefund
+		}
+		return s.SStoreReset, nil
+	}
+
+	if original != zero {
+		if current == zero {
+			// the refund was added when the slot was cleared before
+			vm.quotaReturn -= min(vm.quotaReturn, s.SStoreRefund)
+		} else if value == zero {
+			vm.quotaReturn += s.SStoreRefund
+		}
 	}
+	if original == value {
+		paid := s.SStoreReset
+		if original == zero {
+			paid = s.SStoreSet
+		}
+		if paid > s.SStoreDirty {
+			vm.quotaReturn += paid - s.SStoreDirty
+		}
+	}
+	return s.SStoreDirty, nil
 }
 
 func gasPush(vm *VM, contract *contract, stack *stack, mem *memory, memorySize uint64) (uint64, error) {
-	return fastestStepGas, nil
+	return vm.quotaSchedule.FastestStep, nil
 }
 
 func gasDup(vm *VM, contract *contract, stack *stack, mem *memory, memorySize uint64) (uint64, error) {
-	return fastestStepGas, nil
+	return vm.quotaSchedule.FastestStep, nil
 }
 
 func gasSwap(vm *VM, contract *contract, stack *stack, mem *memory, memorySize uint64) (uint64, error) {
-	return fastestStepGas, nil
+	return vm.quotaSchedule.FastestStep, nil
 }
 
 func makeGasLog(n uint64) gasFunc {
 	return func(vm *VM, contract *contract, stack *stack, mem *memory, memorySize uint64) (uint64, error) {
-		requestedSize, overflow := bigUint64(stack.back(1))
+		requestedSize, overflow := stack.back(1).uint64WithOverflow()
 		if overflow {
 			return 0, errGasUintOverflow
 		}
 
-		gas, err := memoryGasCost(mem, memorySize)
+		gas, err := memoryGasCost(vm.quotaSchedule, mem, memorySize)
 		if err != nil {
 			return 0, err
 		}
 
-		if gas, overflow = SafeAdd(gas, logGas); overflow {
+		if gas, overflow = SafeAdd(gas, vm.quotaSchedule.Log); overflow {
 			return 0, errGasUintOverflow
 		}
-		if gas, overflow = SafeAdd(gas, n*logTopicGas); overflow {
+		if gas, overflow = SafeAdd(gas, n*vm.quotaSchedule.LogTopic); overflow {
 			return 0, errGasUintOverflow
 		}
 
 		var memorySizeGas uint64
-		if memorySizeGas, overflow = SafeMul(requestedSize, logDataGas); overflow {
+		if memorySizeGas, overflow = SafeMul(requestedSize, vm.quotaSchedule.LogData); overflow {
 			return 0, errGasUintOverflow
 		}
 		if gas, overflow = SafeAdd(gas, memorySizeGas); overflow {
@@ -278,32 +332,88 @@ func makeGasLog(n uint64) gasFunc {
 	}
 }
 
+func gasCall(vm *VM, contract *contract, stack *stack, mem *memory, memorySize uint64) (uint64, error) {
+	gas, err := memoryGasCost(vm.quotaSchedule, mem, memorySize)
+	if err != nil {
+		return 0, err
+	}
+	var overflow bool
+	if gas, overflow = SafeAdd(gas, vm.quotaSchedule.Call); overflow {
+		return 0, errGasUintOverflow
+	}
+	return gas, nil
+}
+
+func gasCreate(vm *VM, contract *contract, stack *stack, mem *memory, memorySize uint64) (uint64, error) {
+	gas, err := memoryGasCost(vm.quotaSchedule, mem, memorySize)
+	if err != nil {
+		return 0, err
+	}
+	var overflow bool
+	if gas, overflow = SafeAdd(gas, vm.quotaSchedule.Create); overflow {
+		return 0, errGasUintOverflow
+	}
+	return gas, nil
+}
+
+func gasCreate2(vm *VM, contract *contract, stack *stack, mem *memory, memorySize uint64) (uint64, error) {
+	gas, err := gasCreate(vm, contract, stack, mem, memorySize)
+	if err != nil {
+		return 0, err
+	}
+
+	// init code is hashed to derive the contract address
+	wordGas, overflow := stack.back(3).uint64WithOverflow()
+	if overflow {
+		return 0, errGasUintOverflow
+	}
+	if wordGas, overflow = SafeMul(toWordSize(wordGas), vm.quotaSchedule.Blake2bWord); overflow {
+		return 0, errGasUintOverflow
+	}
+	if gas, overflow = SafeAdd(gas, wordGas); overflow {
+		return 0, errGasUintOverflow
+	}
+	return gas, nil
+}
+
 func gasDelegateCall(vm *VM, contract *contract, stack *stack, mem *memory, memorySize uint64) (uint64, error) {
-	gas, err := memoryGasCost(mem, memorySize)
+	gas, err := memoryGasCost(vm.quotaSchedule, mem, memorySize)
 	if err != nil {
 		return 0, err
 	}
 	var overflow bool
-	if gas, overflow = SafeAdd(gas, callGas); overflow {
+	if gas, overflow = SafeAdd(gas, vm.quotaSchedule.Call); overflow {
+		return 0, errGasUintOverflow
+	}
+	return gas, nil
+}
+
+func gasStaticCall(vm *VM, contract *contract, stack *stack, mem *memory, memorySize uint64) (uint64, error) {
+	gas, err := memoryGasCost(vm.quotaSchedule, mem, memorySize)
+	if err != nil {
+		return 0, err
+	}
+	var overflow bool
+	if gas, overflow = SafeAdd(gas, vm.quotaSchedule.Call); overflow {
 		return 0, errGasUintOverflow
 	}
 	return gas, nil
 }
 
 func gasReturn(vm *VM, contract *contract, stack *stack, mem *memory, memorySize uint64) (uint64, error) {
-	return memoryGasCost(mem, memorySize)
+	return memoryGasCost(vm.quotaSchedule, mem, memorySize)
 }
 
 func gasRevert(vm *VM, contract *contract, stack *stack, mem *memory, memorySize uint64) (uint64, error) {
-	return memoryGasCost(mem, memorySize)
+	return memoryGasCost(vm.quotaSchedule, mem, memorySize)
 }
 
-func intrinsicGasCost(data []byte, isCreate bool) (uint64, error) {
+func intrinsicGasCost(s *QuotaSchedule, data []byte, isCreate bool) (uint64, error) {
 	var gas uint64
 	if isCreate {
-		gas = txGasContractCreation
+		gas = s.TxContractCreation
 	} else {
-		gas = txGas
+		gas = s.Tx
 	}
 	if len(data) > 0 {
 		var nonZeroByteCount uint64
@@ -312,16 +422,22 @@ func intrinsicGasCost(data []byte, isCreate bool) (uint64, error) {
 				nonZeroByteCount++
 			}
 		}
-		if (maxUint64-gas)/txDataNonZeroGas < nonZeroByteCount {
+		nonZeroGas, overflow := SafeMul(nonZeroByteCount, s.TxDataNonZero)
+		if overflow {
+			return 0, errGasUintOverflow
+		}
+		if gas, overflow = SafeAdd(gas, nonZeroGas); overflow {
 			return 0, errGasUintOverflow
 		}
-		gas += nonZeroByteCount * txDataNonZeroGas
 
 		zeroByteCount := uint64(len(data)) - nonZeroByteCount
-		if (maxUint64-gas)/txDataZeroGas < zeroByteCount {
+		zeroGas, overflow := SafeMul(zeroByteCount, s.TxDataZero)
+		if overflow {
+			return 0, errGasUintOverflow
+		}
+		if gas, overflow = SafeAdd(gas, zeroGas); overflow {
 			return 0, errGasUintOverflow
 		}
-		gas += zeroByteCount * txDataZeroGas
 	}
 	return gas, nil
 }
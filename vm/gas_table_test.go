@@ -1,11 +1,16 @@
 package vm
 
-import "testing"
+import (
+	"encoding/hex"
+	"github.com/vitelabs/go-vite/common/types"
+	"math/big"
+	"testing"
+)
 
 func TestMemoryGasCost(t *testing.T) {
 	// size := uint64(maxUint64 - 64)
 	size := uint64(0xffffffffe0)
-	v, err := memoryGasCost(&memory{}, size)
+	v, err := memoryGasCost(DefaultQuotaSchedule(), &memory{}, size)
 	if err != nil {
 		t.Error("didn't expect error:", err)
 	}
@@ -13,8 +18,87 @@ func TestMemoryGasCost(t *testing.T) {
 		t.Errorf("Expected: 36028899963961341, got %d", v)
 	}
 
-	_, err = memoryGasCost(&memory{}, size+1)
+	_, err = memoryGasCost(DefaultQuotaSchedule(), &memory{}, size+1)
 	if err == nil {
 		t.Error("expected error")
 	}
 }
+
+func TestGasSStore(t *testing.T) {
+	tests := []struct {
+		original byte
+		values   []byte
+		quota    uint64 // charged for the SSTOREs
+		refund   uint64
+	}{
+		{0, []byte{0}, 200, 0},
+		{0, []byte{1}, 20000, 0},
+		{0, []byte{1, 0}, 20200, 19800},
+		{0, []byte{1, 2}, 20200, 0},
+		{0, []byte{1, 2, 0}, 20400, 19800},
+		{0, []byte{1, 0, 1}, 40200, 19800},
+		{1, []byte{0}, 5000, 15000},
+		{1, []byte{1}, 200, 0},
+		{1, []byte{2}, 5000, 0},
+		{1, []byte{2, 1}, 5200, 4800},
+		{1, []byte{0, 1}, 5200, 4800},
+		{1, []byte{0, 2}, 5200, 0},
+		{1, []byte{2, 0}, 5200, 15000},
+		{1, []byte{0, 0}, 5200, 15000},
+	}
+	for i, test := range tests {
+		quota, refund := runSStores(t, DefaultQuotaSchedule(), test.original, test.values)
+		if quota != test.quota || refund != test.refund {
+			t.Errorf("test %v: expected quota %v and refund %v, got %v and %v", i, test.quota, test.refund, quota, refund)
+		}
+	}
+}
+
+func TestGasSStore_Reset(t *testing.T) {
+	s := DefaultQuotaSchedule()
+	s.SStoreReset = 6000
+	tests := []struct {
+		values []byte
+		quota  uint64
+		refund uint64
+	}{
+		{[]byte{0}, 6000, 15000},
+		{[]byte{2}, 6000, 0},
+		{[]byte{0, 1}, 6200, 5800},
+		{[]byte{2, 1}, 6200, 5800},
+	}
+	for i, test := range tests {
+		quota, refund := runSStores(t, s, 1, test.values)
+		if quota != test.quota || refund != test.refund {
+			t.Errorf("test %v: expected quota %v and refund %v, got %v and %v", i, test.quota, test.refund, quota, refund)
+		}
+	}
+}
+
+// runSStores stores every value in turn to a slot committed as original and
+// returns the quota charged for the SSTOREs and the refund.
+func runSStores(t *testing.T, s *QuotaSchedule, original byte, values []byte) (uint64, uint64) {
+	db := NewMemoryDatabase()
+	to := testAddress(2)
+	if original != 0 {
+		db.SetState(to, testHash(0), testHash(int64(original)))
+	}
+	db.Commit()
+	// sstore(0, value) for every value; stop
+	code := ""
+	for _, v := range values {
+		code += hex.EncodeToString([]byte{byte(PUSH1), v, byte(PUSH1), 0, byte(SSTORE)})
+	}
+	c, _ := hex.DecodeString(code + "00")
+
+	vm := NewVM(Transaction{Depth: 1})
+	vm.StateDb = db
+	vm.quotaSchedule = s
+	vm.quotaLeft = 1000000
+	contract := newContract(testAddress(1), to, types.TokenTypeId{}, new(big.Int), nil)
+	contract.setCallCode(to, types.DataHash(c), c)
+	if _, err := run(vm, contract); err != nil {
+		t.Fatalf("run failed, %v", err)
+	}
+	return 1000000 - vm.quotaLeft - uint64(len(values))*2*s.FastestStep, vm.quotaReturn
+}
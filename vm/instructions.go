@@ -1,10 +1,11 @@
 package vm
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/vitelabs/go-vite/common/types"
 	"github.com/vitelabs/go-vite/crypto"
-	"math/big"
 )
 
 func opStop(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
@@ -13,364 +14,242 @@ func opStop(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack
 
 func opAdd(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	x, y := stack.pop(), stack.peek()
-	U256(y.Add(x, y))
-
-	vm.intPool.put(x)
+	y.add(&x, y)
 	return nil, nil
 }
 
 func opMul(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	x, y := stack.pop(), stack.peek()
-	U256(y.Mul(x, y))
-
-	vm.intPool.put(x)
+	y.mul(&x, y)
 	return nil, nil
 }
 
 func opSub(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	x, y := stack.pop(), stack.peek()
-	U256(y.Sub(x, y))
-
-	vm.intPool.put(x)
+	y.sub(&x, y)
 	return nil, nil
 }
 
 func opDiv(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	x, y := stack.pop(), stack.peek()
-	if y.Sign() != 0 {
-		U256(y.Div(x, y))
-	} else {
-		y.SetUint64(0)
-	}
-
-	vm.intPool.put(x)
+	y.div(&x, y)
 	return nil, nil
 }
 
 func opSdiv(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	x, y := S256(stack.pop()), S256(stack.pop())
-	res := vm.intPool.getZero()
-
-	if y.Sign() == 0 || x.Sign() == 0 {
-		stack.push(res)
-	} else {
-		if x.Sign() != y.Sign() {
-			res.Div(x.Abs(x), y.Abs(y))
-			res.Neg(res)
-		} else {
-			res.Div(x.Abs(x), y.Abs(y))
-		}
-		stack.push(U256(res))
-	}
-
-	vm.intPool.put(x, y)
+	x, y := stack.pop(), stack.peek()
+	y.sdiv(&x, y)
 	return nil, nil
 }
 
 func opMod(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	x, y := stack.pop(), stack.pop()
-	if y.Sign() == 0 {
-		stack.push(x.SetUint64(0))
-	} else {
-		stack.push(U256(x.Mod(x, y)))
-	}
-
-	vm.intPool.put(y)
+	x, y := stack.pop(), stack.peek()
+	y.mod(&x, y)
 	return nil, nil
 }
 
 func opSmod(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	x, y := S256(stack.pop()), S256(stack.pop())
-	res := vm.intPool.getZero()
-
-	if y.Sign() == 0 {
-		stack.push(res)
-	} else {
-		if x.Sign() < 0 {
-			res.Mod(x.Abs(x), y.Abs(y))
-			res.Neg(res)
-		} else {
-			res.Mod(x.Abs(x), y.Abs(y))
-		}
-		stack.push(U256(res))
-	}
-
-	vm.intPool.put(x, y)
+	x, y := stack.pop(), stack.peek()
+	y.smod(&x, y)
 	return nil, nil
 }
 
 func opAddmod(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	x, y, z := stack.pop(), stack.pop(), stack.pop()
-	if z.Cmp(bigZero) > 0 {
-		x.Add(x, y)
-		x.Mod(x, z)
-		stack.push(U256(x))
-	} else {
-		stack.push(x.SetUint64(0))
-	}
-
-	vm.intPool.put(y, z)
+	x, y, z := stack.pop(), stack.pop(), stack.peek()
+	z.addMod(&x, &y, z)
 	return nil, nil
 }
 
 func opMulmod(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	x, y, z := stack.pop(), stack.pop(), stack.pop()
-	if z.Cmp(bigZero) > 0 {
-		x.Mul(x, y)
-		x.Mod(x, z)
-		stack.push(U256(x))
-	} else {
-		stack.push(x.SetUint64(0))
-	}
-
-	vm.intPool.put(y, z)
+	x, y, z := stack.pop(), stack.pop(), stack.peek()
+	z.mulMod(&x, &y, z)
 	return nil, nil
 }
 
 func opExp(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	base, exponent := stack.pop(), stack.pop()
-	stack.push(Exp(base, exponent))
-
-	vm.intPool.put(base, exponent)
+	base, exponent := stack.pop(), stack.peek()
+	exponent.exp(&base, exponent)
 	return nil, nil
 }
 
 func opSignExtend(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	back := stack.pop()
-	if back.Cmp(big.NewInt(31)) < 0 {
-		bit := uint(back.Uint64()*8 + 7)
-		num := stack.pop()
-		mask := back.Lsh(big1, bit)
-		mask.Sub(mask, big1)
-		if num.Bit(int(bit)) > 0 {
-			num.Or(num, mask.Not(mask))
-		} else {
-			num.And(num, mask)
-		}
-		stack.push(U256(num))
+	back, num := stack.pop(), stack.peek()
+	if back.isUint64() && back[0] < 31 {
+		num.signExtend(num, back[0])
 	}
-
-	vm.intPool.put(back)
 	return nil, nil
 }
 
 func opLt(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	x, y := stack.pop(), stack.peek()
-	if x.Cmp(y) < 0 {
-		y.SetUint64(1)
+	if x.lt(y) {
+		y.setUint64(1)
 	} else {
-		y.SetUint64(0)
+		y.clear()
 	}
-	vm.intPool.put(x)
 	return nil, nil
 }
 
 func opGt(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	x, y := stack.pop(), stack.peek()
-	if x.Cmp(y) > 0 {
-		y.SetUint64(1)
+	if x.gt(y) {
+		y.setUint64(1)
 	} else {
-		y.SetUint64(0)
+		y.clear()
 	}
-	vm.intPool.put(x)
 	return nil, nil
 }
 
 func opSlt(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	x, y := stack.pop(), stack.peek()
-
-	xSign := x.Cmp(tt255)
-	ySign := y.Cmp(tt255)
-
-	switch {
-	case xSign >= 0 && ySign < 0:
-		y.SetUint64(1)
-
-	case xSign < 0 && ySign >= 0:
-		y.SetUint64(0)
-
-	default:
-		if x.Cmp(y) < 0 {
-			y.SetUint64(1)
-		} else {
-			y.SetUint64(0)
-		}
+	if x.slt(y) {
+		y.setUint64(1)
+	} else {
+		y.clear()
 	}
-	vm.intPool.put(x)
 	return nil, nil
 }
 
 func opSgt(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	x, y := stack.pop(), stack.peek()
-
-	xSign := x.Cmp(tt255)
-	ySign := y.Cmp(tt255)
-
-	switch {
-	case xSign >= 0 && ySign < 0:
-		y.SetUint64(0)
-
-	case xSign < 0 && ySign >= 0:
-		y.SetUint64(1)
-
-	default:
-		if x.Cmp(y) > 0 {
-			y.SetUint64(1)
-		} else {
-			y.SetUint64(0)
-		}
+	if x.sgt(y) {
+		y.setUint64(1)
+	} else {
+		y.clear()
 	}
-	vm.intPool.put(x)
 	return nil, nil
 }
 
 func opEq(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	x, y := stack.pop(), stack.peek()
-	if x.Cmp(y) == 0 {
-		y.SetUint64(1)
+	if x.eq(y) {
+		y.setUint64(1)
 	} else {
-		y.SetUint64(0)
+		y.clear()
 	}
-	vm.intPool.put(x)
 	return nil, nil
 }
 
 func opIszero(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	x := stack.peek()
-	if x.Sign() > 0 {
-		x.SetUint64(0)
+	if x.isZero() {
+		x.setUint64(1)
 	} else {
-		x.SetUint64(1)
+		x.clear()
 	}
 	return nil, nil
 }
 
 func opAnd(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	x, y := stack.pop(), stack.pop()
-	stack.push(x.And(x, y))
-
-	vm.intPool.put(y)
+	x, y := stack.pop(), stack.peek()
+	y.and(&x, y)
 	return nil, nil
 }
 
 func opOr(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	x, y := stack.pop(), stack.peek()
-	y.Or(x, y)
-
-	vm.intPool.put(x)
+	y.or(&x, y)
 	return nil, nil
 }
 
 func opXor(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	x, y := stack.pop(), stack.peek()
-	y.Xor(x, y)
-
-	vm.intPool.put(x)
+	y.xor(&x, y)
 	return nil, nil
 }
 
 func opNot(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	x := stack.peek()
-	U256(x.Not(x))
+	x.not(x)
 	return nil, nil
 }
 
 func opByte(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	th, val := stack.pop(), stack.peek()
-	if th.Cmp(big32) < 0 {
-		b := Byte(val, 32, int(th.Int64()))
-		val.SetUint64(uint64(b))
+	if th.isUint64() && th[0] < 32 {
+		val.setUint64(uint64(val.byteAt(th[0])))
 	} else {
-		val.SetUint64(0)
+		val.clear()
 	}
-
-	vm.intPool.put(th)
 	return nil, nil
 }
 
 func opSHL(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	shift, value := U256(stack.pop()), U256(stack.peek())
-	if shift.Cmp(Big256) >= 0 {
-		value.SetUint64(0)
+	shift, value := stack.pop(), stack.peek()
+	if shift.isUint64() && shift[0] < 256 {
+		value.lsh(value, uint(shift[0]))
 	} else {
-		U256(value.Lsh(value, uint(shift.Uint64())))
+		value.clear()
 	}
-
-	vm.intPool.put(shift)
 	return nil, nil
 }
 
 func opSHR(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	shift, value := U256(stack.pop()), U256(stack.peek())
-	if shift.Cmp(Big256) >= 0 {
-		value.SetUint64(0)
+	shift, value := stack.pop(), stack.peek()
+	if shift.isUint64() && shift[0] < 256 {
+		value.rsh(value, uint(shift[0]))
 	} else {
-		U256(value.Rsh(value, uint(shift.Uint64())))
+		value.clear()
 	}
-
-	vm.intPool.put(shift)
 	return nil, nil
 }
 
 func opSAR(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	shift, value := U256(stack.pop()), S256(stack.pop())
-	if shift.Cmp(Big256) >= 0 {
-		if value.Sign() > 0 {
-			value.SetUint64(0)
-		} else {
-			value.SetInt64(-1)
-		}
-		stack.push(U256(value))
+	shift, value := stack.pop(), stack.peek()
+	if shift.isUint64() && shift[0] < 256 {
+		value.srsh(value, uint(shift[0]))
 	} else {
-		stack.push(U256(value.Rsh(value, uint(shift.Uint64()))))
+		// everything is shifted out, only the sign bit is left
+		value.srsh(value, 256)
 	}
-
-	vm.intPool.put(shift)
 	return nil, nil
 }
 
 func opBlake2b(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	offset, size := stack.pop(), stack.pop()
-	data := memory.get(offset.Int64(), size.Int64())
-	hash := crypto.Hash256(data)
-	stack.push(vm.intPool.get().SetBytes(hash))
-
-	vm.intPool.put(offset, size)
+	offset, size := stack.pop(), stack.peek()
+	data := memory.getPtr(int64(offset[0]), int64(size[0]))
+	size.setBytes(crypto.Hash256(data))
 	return nil, nil
 }
 
 func opAddress(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	stack.push(vm.intPool.get().SetBytes(contract.address.Bytes()))
+	var addr word
+	stack.push(addr.setBytes(contract.address.Bytes()))
 	return nil, nil
 }
 
 func opBalance(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	addrBig, tokenTypeIdBig := stack.pop(), stack.pop()
-	address, _ := types.BytesToAddress(addrBig.Bytes())
-	tokenTypeId, _ := types.BytesToTokenTypeId(tokenTypeIdBig.Bytes())
-	stack.push(vm.StateDb.GetBalance(address, tokenTypeId))
-
-	vm.intPool.put(addrBig, tokenTypeIdBig)
+	addr, tokenTypeId := stack.pop(), stack.peek()
+	balance := vm.StateDb.GetBalance(wordToAddress(&addr), wordToTokenTypeId(tokenTypeId))
+	tokenTypeId.setBig(balance)
 	return nil, nil
 }
 
 func opCaller(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	stack.push(vm.intPool.get().SetBytes(contract.caller.Bytes()))
+	var caller word
+	stack.push(caller.setBytes(contract.caller.Bytes()))
 	return nil, nil
 }
 
 func opCallValue(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	stack.push(contract.amount)
+	var amount word
+	stack.push(amount.setBig(contract.amount))
 	return nil, nil
 }
 
 func opCallDataLoad(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	stack.push(vm.intPool.get().SetBytes(getDataBig(contract.data, stack.pop(), big32)))
+	x := stack.peek()
+	if offset, overflow := x.uint64WithOverflow(); !overflow {
+		x.setBytes(getData(contract.data, offset, 32))
+	} else {
+		x.clear()
+	}
 	return nil, nil
 }
 
 func opCallDataSize(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	stack.push(vm.intPool.get().SetInt64(int64(len(contract.data))))
+	var size word
+	stack.push(size.setUint64(uint64(len(contract.data))))
 	return nil, nil
 }
 
@@ -380,14 +259,13 @@ func opCallDataCopy(pc *uint64, vm *VM, contract *contract, memory *memory, stac
 		dataOffset = stack.pop()
 		length     = stack.pop()
 	)
-	memory.set(memOffset.Uint64(), length.Uint64(), getDataBig(contract.data, dataOffset, length))
-
-	vm.intPool.put(memOffset, dataOffset, length)
+	memory.set(memOffset[0], length[0], getData(contract.data, offsetOrMax(&dataOffset), length[0]))
 	return nil, nil
 }
 
 func opCodeSize(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	stack.push(vm.intPool.get().SetInt64(int64(len(contract.code))))
+	var size word
+	stack.push(size.setUint64(uint64(len(contract.code))))
 	return nil, nil
 }
 
@@ -397,16 +275,13 @@ func opCodeCopy(pc *uint64, vm *VM, contract *contract, memory *memory, stack *s
 		codeOffset = stack.pop()
 		length     = stack.pop()
 	)
-	memory.set(memOffset.Uint64(), length.Uint64(), getDataBig(contract.code, codeOffset, length))
-
-	vm.intPool.put(memOffset, codeOffset, length)
+	memory.set(memOffset[0], length[0], getData(contract.code, offsetOrMax(&codeOffset), length[0]))
 	return nil, nil
 }
 
 func opExtCodeSize(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	addr := stack.peek()
-	contractAddress, _ := types.BytesToAddress(addr.Bytes())
-	addr.SetUint64(uint64(vm.StateDb.GetContractCodeSize(contractAddress)))
+	addr.setUint64(uint64(vm.StateDb.GetContractCodeSize(wordToAddress(addr))))
 	return nil, nil
 }
 
@@ -417,16 +292,23 @@ func opExtCodeCopy(pc *uint64, vm *VM, contract *contract, memory *memory, stack
 		codeOffset = stack.pop()
 		length     = stack.pop()
 	)
-	contractAddress, _ := types.BytesToAddress(addr.Bytes())
-	codeCopy := getDataBig(vm.StateDb.GetContractCode(contractAddress), codeOffset, length)
-	memory.set(memOffset.Uint64(), length.Uint64(), codeCopy)
-
-	vm.intPool.put(addr, memOffset, codeOffset, length)
+	codeCopy := getData(vm.StateDb.GetContractCode(wordToAddress(&addr)), offsetOrMax(&codeOffset), length[0])
+	memory.set(memOffset[0], length[0], codeCopy)
 	return nil, nil
 }
 
+// offsetOrMax returns offset as a uint64, saturating so that reading past the
+// end of some data yields only padding.
+func offsetOrMax(offset *word) uint64 {
+	if o, overflow := offset.uint64WithOverflow(); !overflow {
+		return o
+	}
+	return maxUint64
+}
+
 func opReturnDataSize(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	stack.push(vm.intPool.get().SetUint64(uint64(len(vm.returnData))))
+	var size word
+	stack.push(size.setUint64(uint64(len(vm.returnData))))
 	return nil, nil
 }
 
@@ -435,132 +317,122 @@ func opReturnDataCopy(pc *uint64, vm *VM, contract *contract, memory *memory, st
 		memOffset  = stack.pop()
 		dataOffset = stack.pop()
 		length     = stack.pop()
-
-		end = vm.intPool.get().Add(dataOffset, length)
 	)
-	defer vm.intPool.put(memOffset, dataOffset, length, end)
-
-	if end.BitLen() > 64 || uint64(len(vm.returnData)) < end.Uint64() {
+	offset, overflow := dataOffset.uint64WithOverflow()
+	if overflow {
 		return nil, errReturnDataOutOfBounds
 	}
-	memory.set(memOffset.Uint64(), length.Uint64(), vm.returnData[dataOffset.Uint64():end.Uint64()])
-
+	end, overflow := SafeAdd(offset, length[0])
+	if overflow || uint64(len(vm.returnData)) < end {
+		return nil, errReturnDataOutOfBounds
+	}
+	memory.set(memOffset[0], length[0], vm.returnData[offset:end])
 	return nil, nil
 }
 
 func opExtCodeHash(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	addr := stack.peek()
-	contractAddress, _ := types.BytesToAddress(addr.Bytes())
-	addr.SetBytes(vm.StateDb.GetContractCodeHash(contractAddress).Bytes())
+	addr.setBytes(vm.StateDb.GetContractCodeHash(wordToAddress(addr)).Bytes())
 	return nil, nil
 }
 
 func opBlockHash(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	num := stack.pop()
-	n := vm.intPool.get().Sub(vm.SnapshotHeight, Big256)
-	if num.Cmp(n) > 0 && num.Cmp(vm.SnapshotHeight) <= 0 {
-		stack.push(vm.StateDb.GetHash(num.Uint64()).Big())
+	num := stack.peek()
+	var height uint64
+	if vm.SnapshotHeight != nil {
+		height = vm.SnapshotHeight.Uint64()
+	}
+	// only the last 256 snapshots are available
+	if n, overflow := num.uint64WithOverflow(); !overflow && n <= height && (height < 256 || n > height-256) {
+		num.setBytes(vm.StateDb.GetHash(n).Bytes())
 	} else {
-		stack.push(vm.intPool.getZero())
+		num.clear()
 	}
-
-	vm.intPool.put(num, n)
 	return nil, nil
 }
 
 func opTimestamp(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	stack.push(U256(vm.intPool.get().Set(vm.SnapshotTimestamp)))
+	var timestamp word
+	stack.push(timestamp.setBig(vm.SnapshotTimestamp))
 	return nil, nil
 }
 
 func opNumber(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	stack.push(U256(vm.intPool.get().Set(vm.SnapshotHeight)))
+	var height word
+	stack.push(height.setBig(vm.SnapshotHeight))
 	return nil, nil
 }
 
 func opPop(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	vm.intPool.put(stack.pop())
+	stack.pop()
 	return nil, nil
 }
 
 func opMload(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	offset := stack.pop()
-	val := vm.intPool.get().SetBytes(memory.get(offset.Int64(), 32))
-	stack.push(val)
-
-	vm.intPool.put(offset)
+	v := stack.peek()
+	v.setBytes(memory.getPtr(int64(v[0]), 32))
 	return nil, nil
 }
 
 func opMstore(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	// pop amount of the stack
 	mStart, val := stack.pop(), stack.pop()
-	memory.set32(mStart.Uint64(), val)
-
-	vm.intPool.put(mStart, val)
+	memory.set32(mStart[0], &val)
 	return nil, nil
 }
 
 func opMstore8(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	off, val := stack.pop().Int64(), stack.pop().Int64()
-	memory.store[off] = byte(val & 0xff)
-
+	off, val := stack.pop(), stack.pop()
+	memory.store[off[0]] = byte(val[0])
 	return nil, nil
 }
 
 func opSLoad(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	loc := stack.peek()
-	locHash, _ := types.BigToHash(loc)
-	val := vm.StateDb.GetState(contract.address, locHash)
-	loc.SetBytes(val.Bytes())
+	val := vm.StateDb.GetState(contract.address, wordToHash(loc))
+	loc.setBytes(val.Bytes())
 	return nil, nil
 }
 
 func opSStore(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	locBig, valBig := stack.pop(), stack.pop()
-	loc, _ := types.BigToHash(locBig)
-	val, _ := types.BigToHash(valBig)
-	vm.StateDb.SetState(contract.address, loc, val)
-
-	vm.intPool.put(locBig, valBig)
+	loc, val := stack.pop(), stack.pop()
+	vm.StateDb.SetState(contract.address, wordToHash(&loc), wordToHash(&val))
 	return nil, nil
 }
 
 func opJump(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	pos := stack.pop()
-	if !contract.jumpdests.has(contract.codeHash, contract.code, pos) {
-		nop := contract.getOp(pos.Uint64())
-		return nil, fmt.Errorf("invalid jump destination (%v) %v", nop, pos)
+	if !contract.jumpdests.has(vm.jumpdestCache(), contract.codeHash, contract.code, &pos) {
+		nop := contract.getOp(pos[0])
+		return nil, fmt.Errorf("invalid jump destination (%v) %v", nop, pos.big())
 	}
-	*pc = pos.Uint64()
-
-	vm.intPool.put(pos)
+	*pc = pos[0]
 	return nil, nil
 }
 
 func opJumpi(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	pos, cond := stack.pop(), stack.pop()
-	if cond.Sign() != 0 {
-		if !contract.jumpdests.has(contract.codeHash, contract.code, pos) {
-			nop := contract.getOp(pos.Uint64())
-			return nil, fmt.Errorf("invalid jump destination (%v) %v", nop, pos)
+	if !cond.isZero() {
+		if !contract.jumpdests.has(vm.jumpdestCache(), contract.codeHash, contract.code, &pos) {
+			nop := contract.getOp(pos[0])
+			return nil, fmt.Errorf("invalid jump destination (%v) %v", nop, pos.big())
 		}
-		*pc = pos.Uint64()
+		*pc = pos[0]
 	} else {
 		*pc++
 	}
-
-	vm.intPool.put(pos, cond)
 	return nil, nil
 }
 
 func opPc(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	stack.push(vm.intPool.get().SetUint64(*pc))
+	var v word
+	stack.push(v.setUint64(*pc))
 	return nil, nil
 }
 
 func opMsize(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-	stack.push(vm.intPool.get().SetInt64(int64(memory.len())))
+	var size word
+	stack.push(size.setUint64(uint64(memory.len())))
 	return nil, nil
 }
 
@@ -583,8 +455,8 @@ func makePush(size uint64, pushByteSize int) executionFunc {
 			endMin = startMin + pushByteSize
 		}
 
-		integer := vm.intPool.get()
-		stack.push(integer.SetBytes(rightPadBytes(contract.code[startMin:endMin], pushByteSize)))
+		var integer word
+		stack.push(integer.setBytes(rightPadBytes(contract.code[startMin:endMin], pushByteSize)))
 
 		*pc += size
 		return nil, nil
@@ -594,7 +466,7 @@ func makePush(size uint64, pushByteSize int) executionFunc {
 // make dup instruction function
 func makeDup(size int64) executionFunc {
 	return func(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
-		stack.dup(vm.intPool, int(size))
+		stack.dup(int(size))
 		return nil, nil
 	}
 }
@@ -614,52 +486,154 @@ func makeLog(size int) executionFunc {
 		topics := make([]types.Hash, size)
 		mStart, mSize := stack.pop(), stack.pop()
 		for i := 0; i < size; i++ {
-			topics[i], _ = types.BigToHash(stack.pop())
+			topic := stack.pop()
+			topics[i] = wordToHash(&topic)
 		}
 
-		d := memory.get(mStart.Int64(), mSize.Int64())
+		d := memory.get(int64(mStart[0]), int64(mSize[0]))
+		var height uint64
+		if vm.AccountHeight != nil {
+			height = vm.AccountHeight.Uint64()
+		}
 		vm.logs = append(vm.logs, &Log{
 			Address: contract.address,
 			Topics:  topics,
 			Data:    d,
-			Height:  vm.AccountHeight.Uint64(),
+			Height:  height,
 		})
-
-		vm.intPool.put(mStart, mSize)
 		return nil, nil
 	}
 }
 
+func opCall(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
+	toAddr, tokenTypeIdWord, amountWord, inOffset, inSize := stack.pop(), stack.pop(), stack.pop(), stack.pop(), stack.pop()
+	tokenTypeId, amount := wordToTokenTypeId(&tokenTypeIdWord), amountWord.big()
+
+	if !canTransfer(vm.StateDb, contract.address, tokenTypeId, amount, big0) {
+		return nil, ErrInsufficientBalance
+	}
+	// the call is asynchronous: sub balance now and emit a send transaction,
+	// the receiver executes it in its own receive transaction
+	vm.StateDb.SubBalance(contract.address, tokenTypeId, amount)
+	vm.txs = append(vm.txs, &Transaction{
+		From:        contract.address,
+		To:          wordToAddress(&toAddr),
+		TxType:      TxTypeSend,
+		TokenTypeId: tokenTypeId,
+		Amount:      amount,
+		Data:        memory.get(int64(inOffset[0]), int64(inSize[0])),
+		Depth:       vm.Depth + 1,
+	})
+	return nil, nil
+}
+
+func opCreate(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
+	tokenTypeId, amount, offset, size := stack.pop(), stack.pop(), stack.pop(), stack.pop()
+
+	// contracts created at the same height are told apart by the number of
+	// transactions sent before them
+	salt := make([]byte, 8)
+	binary.BigEndian.PutUint64(salt, uint64(len(vm.txs)))
+	return nil, sendCreate(vm, contract, stack, &tokenTypeId, &amount, memory.get(int64(offset[0]), int64(size[0])), &ContractSalt{AccountHeight: vm.AccountHeight, Salt: salt})
+}
+
+func opCreate2(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
+	tokenTypeId, amount, offset, size, salt := stack.pop(), stack.pop(), stack.pop(), stack.pop(), stack.pop()
+
+	saltBytes := salt.bytes32()
+	return nil, sendCreate(vm, contract, stack, &tokenTypeId, &amount, memory.get(int64(offset[0]), int64(size[0])), &ContractSalt{Create2: true, AccountHeight: vm.AccountHeight, Salt: saltBytes[:]})
+}
+
+// sendCreate emits a contract creation send transaction and pushes the
+// address the contract will be created at, or zero if it is already taken.
+func sendCreate(vm *VM, contract *contract, stack *stack, tokenTypeIdWord, amountWord *word, code []byte, salt *ContractSalt) error {
+	tokenTypeId, amount := wordToTokenTypeId(tokenTypeIdWord), amountWord.big()
+	createFee := vm.quotaProvider().CreateContractFee(code)
+	if !canTransfer(vm.StateDb, contract.address, tokenTypeId, amount, createFee) {
+		return ErrInsufficientBalance
+	}
+
+	var result word
+	contractAddr := salt.address(contract.address, code)
+	if vm.StateDb.IsExistAddress(contractAddr) {
+		stack.push(&result)
+		return nil
+	}
+	for _, tx := range vm.txs {
+		if tx.TxType == TxTypeSendCreate && tx.To == contractAddr {
+			stack.push(&result)
+			return nil
+		}
+	}
+
+	vm.StateDb.SubBalance(contract.address, tokenTypeId, amount)
+	vm.StateDb.SubBalance(contract.address, viteTokenTypeId, createFee)
+	vm.txs = append(vm.txs, &Transaction{
+		From:         contract.address,
+		To:           contractAddr,
+		TxType:       TxTypeSendCreate,
+		TokenTypeId:  tokenTypeId,
+		Amount:       amount,
+		Data:         code,
+		Depth:        vm.Depth + 1,
+		ContractSalt: salt,
+	})
+	stack.push(result.setBytes(contractAddr.Bytes()))
+	return nil
+}
+
 func opDelegateCall(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	addr, inOffset, inSize, outOffset, outSize := stack.pop(), stack.pop(), stack.pop(), stack.pop(), stack.pop()
-	contractAddress, _ := types.BytesToAddress(addr.Bytes())
-	data := memory.get(inOffset.Int64(), inSize.Int64())
-	ret, err := vm.delegateCall(contractAddress, data)
+	data := memory.get(int64(inOffset[0]), int64(inSize[0]))
+	ret, err := vm.delegateCall(wordToAddress(&addr), data)
+	if errors.Is(err, ErrVMInternal) {
+		// a bug in the callee fails the whole transaction
+		return nil, err
+	}
+	if err == ErrOutOfQuota {
+		vm.callOutOfQuota = true
+	}
 	if err == nil || err == ErrExecutionReverted {
-		memory.set(outOffset.Uint64(), outSize.Uint64(), ret)
+		memory.set(outOffset[0], outSize[0], ret)
 	}
-	if err != nil {
-		stack.push(vm.intPool.getZero())
-	} else {
-		stack.push(vm.intPool.get().SetUint64(1))
+	var success word
+	if err == nil {
+		success.setUint64(1)
 	}
+	stack.push(&success)
+	return ret, nil
+}
 
-	vm.intPool.put(addr, inOffset, inSize, outOffset, outSize)
+func opStaticCall(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
+	addr, inOffset, inSize, outOffset, outSize := stack.pop(), stack.pop(), stack.pop(), stack.pop(), stack.pop()
+	data := memory.get(int64(inOffset[0]), int64(inSize[0]))
+	ret, err := vm.staticCall(contract.address, wordToAddress(&addr), data)
+	if errors.Is(err, ErrVMInternal) {
+		// a bug in the callee fails the whole transaction
+		return nil, err
+	}
+	if err == ErrOutOfQuota {
+		vm.callOutOfQuota = true
+	}
+	if err == nil || err == ErrExecutionReverted {
+		memory.set(outOffset[0], outSize[0], ret)
+	}
+	var success word
+	if err == nil {
+		success.setUint64(1)
+	}
+	stack.push(&success)
 	return ret, nil
 }
 
 func opReturn(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	offset, size := stack.pop(), stack.pop()
-	ret := memory.getPtr(offset.Int64(), size.Int64())
-
-	vm.intPool.put(offset, size)
+	ret := memory.getPtr(int64(offset[0]), int64(size[0]))
 	return ret, nil
 }
 
 func opRevert(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
 	offset, size := stack.pop(), stack.pop()
-	ret := memory.getPtr(offset.Int64(), size.Int64())
-
-	vm.intPool.put(offset, size)
+	ret := memory.getPtr(int64(offset[0]), int64(size[0]))
 	return ret, nil
 }
@@ -17,7 +17,6 @@ func opBenchmark(bench *testing.B, op func(pc *uint64, vm *VM, contract *contrac
 	vm.StateDb = &testDatabase{}
 	vm.Debug = true
 	vm.quotaLeft = 1000000
-	vm.intPool = poolOfIntPools.get()
 	stack := newStack()
 
 	// convert args
@@ -29,13 +28,12 @@ func opBenchmark(bench *testing.B, op func(pc *uint64, vm *VM, contract *contrac
 	bench.ResetTimer()
 	for i := 0; i < bench.N; i++ {
 		for _, arg := range byteArgs {
-			a := new(big.Int).SetBytes(arg)
-			stack.push(a)
+			var a word
+			stack.push(a.setBytes(arg))
 		}
 		op(&pc, vm, nil, nil, stack)
 		stack.pop()
 	}
-	poolOfIntPools.put(vm.intPool)
 }
 
 func BenchmarkOpAdd64(b *testing.B) {
@@ -265,22 +263,19 @@ func TestByteOp(t *testing.T) {
 	vm.StateDb = &testDatabase{}
 	vm.Debug = true
 	vm.quotaLeft = 1000000
-	vm.intPool = poolOfIntPools.get()
 	stack := newStack()
 	pc := uint64(0)
 	for _, test := range tests {
 		v, _ := hex.DecodeString(test.v)
-		val := new(big.Int).SetBytes(v)
-		th := new(big.Int).SetUint64(test.th)
-		stack.push(val)
-		stack.push(th)
+		var val, th word
+		stack.push(val.setBytes(v))
+		stack.push(th.setUint64(test.th))
 		opByte(&pc, vm, nil, nil, stack)
 		actual := stack.pop()
-		if actual.Cmp(test.expected) != 0 {
+		if actual.big().Cmp(test.expected) != 0 {
 			t.Fatalf("Expected  [%v] %v:th byte to be %v, was %v.", test.v, test.th, test.expected, actual)
 		}
 	}
-	poolOfIntPools.put(vm.intPool)
 }
 
 func testTwoOperandOp(t *testing.T, tests []twoOperandTest, opFn func(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error)) {
@@ -288,7 +283,6 @@ func testTwoOperandOp(t *testing.T, tests []twoOperandTest, opFn func(pc *uint64
 	vm.StateDb = &testDatabase{}
 	vm.Debug = true
 	vm.quotaLeft = 1000000
-	vm.intPool = poolOfIntPools.get()
 	stack := newStack()
 	pc := uint64(0)
 	for i, test := range tests {
@@ -296,15 +290,15 @@ func testTwoOperandOp(t *testing.T, tests []twoOperandTest, opFn func(pc *uint64
 		y, _ := hex.DecodeString(test.y)
 		expected, _ := hex.DecodeString(test.expected)
 		expectedInt := new(big.Int).SetBytes(expected)
-		stack.push(new(big.Int).SetBytes(x))
-		stack.push(new(big.Int).SetBytes(y))
+		var xWord, yWord word
+		stack.push(xWord.setBytes(x))
+		stack.push(yWord.setBytes(y))
 		opFn(&pc, vm, nil, nil, stack)
 		actual := stack.pop()
-		if actual.Cmp(expectedInt) != 0 {
-			t.Errorf("Testcase %d, expected  %v, got %v", i, expectedInt, actual)
+		if actual.big().Cmp(expectedInt) != 0 {
+			t.Errorf("Testcase %d, expected  %v, got %v", i, expectedInt, actual.big())
 		}
 	}
-	poolOfIntPools.put(vm.intPool)
 }
 
 func TestSHL(t *testing.T) {
@@ -406,26 +400,25 @@ func TestOpMstore(t *testing.T) {
 	vm.StateDb = &testDatabase{}
 	vm.Debug = true
 	vm.quotaLeft = 1000000
-	vm.intPool = poolOfIntPools.get()
 	stack := newStack()
 	mem := newMemory()
 	mem.resize(64)
 	pc := uint64(0)
 	v := "abcdef00000000000000abba000000000deaf000000c0de00100000000133700"
 	vbytes, _ := hex.DecodeString("abcdef00000000000000abba000000000deaf000000c0de00100000000133700")
-	stack.push(new(big.Int).SetBytes(vbytes))
-	stack.push(big.NewInt(0))
+	var value, offset word
+	stack.push(value.setBytes(vbytes))
+	stack.push(&offset)
 	opMstore(&pc, vm, nil, mem, stack)
 	if got := hex.EncodeToString(mem.get(0, 32)); got != v {
 		t.Fatalf("Mstore fail, got %v, expected %v", got, v)
 	}
-	stack.push(big.NewInt(0x1))
-	stack.push(big.NewInt(0))
+	stack.push(value.setUint64(0x1))
+	stack.push(&offset)
 	opMstore(&pc, vm, nil, mem, stack)
 	if hex.EncodeToString(mem.get(0, 32)) != "0000000000000000000000000000000000000000000000000000000000000001" {
 		t.Fatalf("Mstore failed to overwrite previous value")
 	}
-	poolOfIntPools.put(vm.intPool)
 }
 
 func BenchmarkOpMstore(bench *testing.B) {
@@ -433,19 +426,17 @@ func BenchmarkOpMstore(bench *testing.B) {
 	vm.StateDb = &testDatabase{}
 	vm.Debug = true
 	vm.quotaLeft = 1000000
-	vm.intPool = poolOfIntPools.get()
 	stack := newStack()
 	mem := newMemory()
 	mem.resize(64)
 	pc := uint64(0)
-	memStart := big.NewInt(0)
-	value := big.NewInt(0x1337)
+	var memStart, value word
+	value.setUint64(0x1337)
 
 	bench.ResetTimer()
 	for i := 0; i < bench.N; i++ {
-		stack.push(value)
-		stack.push(memStart)
+		stack.push(&value)
+		stack.push(&memStart)
 		opMstore(&pc, vm, nil, mem, stack)
 	}
-	poolOfIntPools.put(vm.intPool)
 }
@@ -1,14 +1,10 @@
 package vm
 
-import (
-	"math/big"
-)
-
 type (
 	executionFunc       func(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error)
 	gasFunc             func(*VM, *contract, *stack, *memory, uint64) (uint64, error) // last parameter is the requested memory size as a uint64
 	stackValidationFunc func(*stack) error
-	memorySizeFunc      func(*stack) *big.Int
+	memorySizeFunc      func(*stack) (uint64, bool) // returns the required memory size and whether it overflowed
 )
 
 type operation struct {
@@ -30,9 +26,27 @@ type operation struct {
 }
 
 var (
-	simpleInstructionSet = newInstructionSet()
+	genesisInstructionSet = newInstructionSet()
+	leafInstructionSet    = newLeafInstructionSet()
+	// simpleInstructionSet is the latest instruction set
+	simpleInstructionSet = leafInstructionSet
 )
 
+// newLeafInstructionSet returns the instructions after the Leaf upgrade,
+// which adds STATICCALL.
+func newLeafInstructionSet() [256]operation {
+	instructionSet := newInstructionSet()
+	instructionSet[STATICCALL] = operation{
+		execute:       opStaticCall,
+		gasCost:       gasStaticCall,
+		validateStack: makeStackFunc(5, 1),
+		memorySize:    memoryStaticCall,
+		valid:         true,
+		returns:       true,
+	}
+	return instructionSet
+}
+
 func newInstructionSet() [256]operation {
 	return [256]operation{
 		STOP: {
@@ -44,55 +58,55 @@ func newInstructionSet() [256]operation {
 		},
 		ADD: {
 			execute:       opAdd,
-			gasCost:       constGasFunc(fastestStepGas),
+			gasCost:       gasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		MUL: {
 			execute:       opMul,
-			gasCost:       constGasFunc(fastStepGas),
+			gasCost:       gasFastStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		SUB: {
 			execute:       opSub,
-			gasCost:       constGasFunc(fastestStepGas),
+			gasCost:       gasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		DIV: {
 			execute:       opDiv,
-			gasCost:       constGasFunc(fastStepGas),
+			gasCost:       gasFastStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		SDIV: {
 			execute:       opSdiv,
-			gasCost:       constGasFunc(fastStepGas),
+			gasCost:       gasFastStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		MOD: {
 			execute:       opMod,
-			gasCost:       constGasFunc(fastStepGas),
+			gasCost:       gasFastStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		SMOD: {
 			execute:       opSmod,
-			gasCost:       constGasFunc(fastStepGas),
+			gasCost:       gasFastStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		ADDMOD: {
 			execute:       opAddmod,
-			gasCost:       constGasFunc(midStepGas),
+			gasCost:       gasMidStep,
 			validateStack: makeStackFunc(3, 1),
 			valid:         true,
 		},
 		MULMOD: {
 			execute:       opMulmod,
-			gasCost:       constGasFunc(midStepGas),
+			gasCost:       gasMidStep,
 			validateStack: makeStackFunc(3, 1),
 			valid:         true,
 		},
@@ -104,91 +118,91 @@ func newInstructionSet() [256]operation {
 		},
 		SIGNEXTEND: {
 			execute:       opSignExtend,
-			gasCost:       constGasFunc(fastStepGas),
+			gasCost:       gasFastStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		LT: {
 			execute:       opLt,
-			gasCost:       constGasFunc(fastestStepGas),
+			gasCost:       gasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		GT: {
 			execute:       opGt,
-			gasCost:       constGasFunc(fastestStepGas),
+			gasCost:       gasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		SLT: {
 			execute:       opSlt,
-			gasCost:       constGasFunc(fastestStepGas),
+			gasCost:       gasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		SGT: {
 			execute:       opSgt,
-			gasCost:       constGasFunc(fastestStepGas),
+			gasCost:       gasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		EQ: {
 			execute:       opEq,
-			gasCost:       constGasFunc(fastestStepGas),
+			gasCost:       gasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		ISZERO: {
 			execute:       opIszero,
-			gasCost:       constGasFunc(fastestStepGas),
+			gasCost:       gasFastestStep,
 			validateStack: makeStackFunc(1, 1),
 			valid:         true,
 		},
 		AND: {
 			execute:       opAnd,
-			gasCost:       constGasFunc(fastestStepGas),
+			gasCost:       gasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		OR: {
 			execute:       opOr,
-			gasCost:       constGasFunc(fastestStepGas),
+			gasCost:       gasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		XOR: {
 			execute:       opXor,
-			gasCost:       constGasFunc(fastestStepGas),
+			gasCost:       gasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		NOT: {
 			execute:       opNot,
-			gasCost:       constGasFunc(fastestStepGas),
+			gasCost:       gasFastestStep,
 			validateStack: makeStackFunc(1, 1),
 			valid:         true,
 		},
 		BYTE: {
 			execute:       opByte,
-			gasCost:       constGasFunc(fastestStepGas),
+			gasCost:       gasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		SHL: {
 			execute:       opSHL,
-			gasCost:       constGasFunc(fastestStepGas),
+			gasCost:       gasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		SHR: {
 			execute:       opSHR,
-			gasCost:       constGasFunc(fastestStepGas),
+			gasCost:       gasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		SAR: {
 			execute:       opSAR,
-			gasCost:       constGasFunc(fastestStepGas),
+			gasCost:       gasFastestStep,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
@@ -201,37 +215,37 @@ func newInstructionSet() [256]operation {
 		},
 		ADDRESS: {
 			execute:       opAddress,
-			gasCost:       constGasFunc(quickStepGas),
+			gasCost:       gasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
 		BALANCE: {
 			execute:       opBalance,
-			gasCost:       constGasFunc(balanceGas),
+			gasCost:       gasBalance,
 			validateStack: makeStackFunc(2, 1),
 			valid:         true,
 		},
 		CALLER: {
 			execute:       opCaller,
-			gasCost:       constGasFunc(quickStepGas),
+			gasCost:       gasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
 		CALLVALUE: {
 			execute:       opCallValue,
-			gasCost:       constGasFunc(quickStepGas),
+			gasCost:       gasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
 		CALLDATALOAD: {
 			execute:       opCallDataLoad,
-			gasCost:       constGasFunc(fastestStepGas),
+			gasCost:       gasFastestStep,
 			validateStack: makeStackFunc(1, 1),
 			valid:         true,
 		},
 		CALLDATASIZE: {
 			execute:       opCallDataSize,
-			gasCost:       constGasFunc(quickStepGas),
+			gasCost:       gasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
@@ -244,7 +258,7 @@ func newInstructionSet() [256]operation {
 		},
 		CODESIZE: {
 			execute:       opCodeSize,
-			gasCost:       constGasFunc(quickStepGas),
+			gasCost:       gasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
@@ -257,7 +271,7 @@ func newInstructionSet() [256]operation {
 		},
 		EXTCODESIZE: {
 			execute:       opExtCodeSize,
-			gasCost:       constGasFunc(extCodeSizeGas),
+			gasCost:       gasExtCodeSize,
 			validateStack: makeStackFunc(1, 1),
 			valid:         true,
 		},
@@ -270,7 +284,7 @@ func newInstructionSet() [256]operation {
 		},
 		RETURNDATASIZE: {
 			execute:       opReturnDataSize,
-			gasCost:       constGasFunc(quickStepGas),
+			gasCost:       gasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
@@ -283,31 +297,31 @@ func newInstructionSet() [256]operation {
 		},
 		EXTCODEHASH: {
 			execute:       opExtCodeHash,
-			gasCost:       constGasFunc(extCodeHashGas),
+			gasCost:       gasExtCodeHash,
 			validateStack: makeStackFunc(1, 1),
 			valid:         true,
 		},
 		BLOCKHASH: {
 			execute:       opBlockHash,
-			gasCost:       constGasFunc(extStepGas),
+			gasCost:       gasExtStep,
 			validateStack: makeStackFunc(1, 1),
 			valid:         true,
 		},
 		TIMESTAMP: {
 			execute:       opTimestamp,
-			gasCost:       constGasFunc(quickStepGas),
+			gasCost:       gasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
 		NUMBER: {
 			execute:       opNumber,
-			gasCost:       constGasFunc(quickStepGas),
+			gasCost:       gasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
 		POP: {
 			execute:       opPop,
-			gasCost:       constGasFunc(quickStepGas),
+			gasCost:       gasQuickStep,
 			validateStack: makeStackFunc(1, 0),
 			valid:         true,
 		},
@@ -334,7 +348,7 @@ func newInstructionSet() [256]operation {
 		},
 		SLOAD: {
 			execute:       opSLoad,
-			gasCost:       constGasFunc(sLoadGas),
+			gasCost:       gasSLoad,
 			validateStack: makeStackFunc(1, 1),
 			valid:         true,
 		},
@@ -347,33 +361,33 @@ func newInstructionSet() [256]operation {
 		},
 		JUMP: {
 			execute:       opJump,
-			gasCost:       constGasFunc(midStepGas),
+			gasCost:       gasMidStep,
 			validateStack: makeStackFunc(1, 0),
 			jumps:         true,
 			valid:         true,
 		},
 		JUMPI: {
 			execute:       opJumpi,
-			gasCost:       constGasFunc(slowStepGas),
+			gasCost:       gasSlowStep,
 			validateStack: makeStackFunc(2, 0),
 			jumps:         true,
 			valid:         true,
 		},
 		PC: {
 			execute:       opPc,
-			gasCost:       constGasFunc(quickStepGas),
+			gasCost:       gasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
 		MSIZE: {
 			execute:       opMsize,
-			gasCost:       constGasFunc(quickStepGas),
+			gasCost:       gasQuickStep,
 			validateStack: makeStackFunc(0, 1),
 			valid:         true,
 		},
 		JUMPDEST: {
 			execute:       opJumpdest,
-			gasCost:       constGasFunc(jumpdestGas),
+			gasCost:       gasJumpdest,
 			validateStack: makeStackFunc(0, 0),
 			valid:         true,
 		},
@@ -809,6 +823,22 @@ func newInstructionSet() [256]operation {
 			halts:         true,
 			valid:         true,
 		},
+		CREATE: {
+			execute:       opCreate,
+			gasCost:       gasCreate,
+			validateStack: makeStackFunc(4, 1),
+			memorySize:    memoryCreate,
+			valid:         true,
+			writes:        true,
+		},
+		CALL: {
+			execute:       opCall,
+			gasCost:       gasCall,
+			validateStack: makeStackFunc(5, 0),
+			memorySize:    memoryCall,
+			valid:         true,
+			writes:        true,
+		},
 		DELEGATECALL: {
 			execute:       opDelegateCall,
 			gasCost:       gasDelegateCall,
@@ -817,6 +847,14 @@ func newInstructionSet() [256]operation {
 			valid:         true,
 			returns:       true,
 		},
+		CREATE2: {
+			execute:       opCreate2,
+			gasCost:       gasCreate2,
+			validateStack: makeStackFunc(5, 1),
+			memorySize:    memoryCreate,
+			valid:         true,
+			writes:        true,
+		},
 		REVERT: {
 			execute:       opRevert,
 			gasCost:       gasRevert,
@@ -0,0 +1,103 @@
+package vm
+
+import (
+	"container/list"
+	"github.com/vitelabs/go-vite/common/types"
+	"sync"
+)
+
+const defaultJumpdestCacheSize = 16 * 1024 * 1024 // bytes
+
+// DefaultJumpdestCache is the jump destination analysis cache shared by the
+// VMs without a VMConfig.JumpdestCache.
+var DefaultJumpdestCache = NewJumpdestCache(defaultJumpdestCacheSize)
+
+// JumpdestCache is a least recently used cache of the jump destination
+// analysis of contract code, keyed by code hash, so that the code of hot
+// contracts is analysed once and not on every call. It is safe for concurrent
+// use by several VMs.
+type JumpdestCache struct {
+	mu      sync.Mutex
+	size    int // maximum bytes of bitmaps held
+	entries map[types.Hash]*list.Element
+	lru     *list.List // most recently used first
+	bytes   int        // bytes of bitmaps held
+	stats   JumpdestCacheStats
+}
+
+// JumpdestCacheStats are the metrics of a JumpdestCache.
+type JumpdestCacheStats struct {
+	Hits      uint64 // Analyses found in the cache
+	Misses    uint64 // Analyses not found in the cache, the code was analysed
+	Evictions uint64 // Analyses dropped to stay within the size
+	Len       int    // Analyses in the cache
+	Bytes     int    // Bytes of the analyses in the cache
+}
+
+type jumpdestCacheEntry struct {
+	hash types.Hash
+	bits bitvec
+}
+
+// NewJumpdestCache returns a cache holding up to size bytes of code analysis,
+// about an eighth of the size of the code analysed. A size of 0 disables
+// caching.
+func NewJumpdestCache(size int) *JumpdestCache {
+	if size < 0 {
+		size = 0
+	}
+	return &JumpdestCache{size: size, entries: make(map[types.Hash]*list.Element), lru: list.New()}
+}
+
+// bitmap returns the code bitmap of code, analysing it on a miss.
+func (c *JumpdestCache) bitmap(codehash types.Hash, code []byte) bitvec {
+	c.mu.Lock()
+	if elem, ok := c.entries[codehash]; ok {
+		c.stats.Hits++
+		c.lru.MoveToFront(elem)
+		c.mu.Unlock()
+		return elem.Value.(*jumpdestCacheEntry).bits
+	}
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	bits := codeBitmap(code)
+	if len(bits) > c.size {
+		return bits
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// another VM may have analysed the same code meanwhile
+	if elem, ok := c.entries[codehash]; ok {
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*jumpdestCacheEntry).bits
+	}
+	c.entries[codehash] = c.lru.PushFront(&jumpdestCacheEntry{codehash, bits})
+	c.bytes += len(bits)
+	for c.bytes > c.size {
+		oldest := c.lru.Remove(c.lru.Back()).(*jumpdestCacheEntry)
+		delete(c.entries, oldest.hash)
+		c.bytes -= len(oldest.bits)
+		c.stats.Evictions++
+	}
+	return bits
+}
+
+// Stats returns the metrics of the cache.
+func (c *JumpdestCache) Stats() JumpdestCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Len, stats.Bytes = c.lru.Len(), c.bytes
+	return stats
+}
+
+// Purge drops every analysis from the cache, keeping the metrics.
+func (c *JumpdestCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[types.Hash]*list.Element)
+	c.lru.Init()
+	c.bytes = 0
+}
@@ -0,0 +1,67 @@
+package vm
+
+import (
+	"github.com/vitelabs/go-vite/common/types"
+	"math/big"
+	"sync"
+	"testing"
+)
+
+func TestJumpdestCache(t *testing.T) {
+	// analysed into a 5 byte bitmap
+	code := []byte{byte(PUSH1), byte(JUMPDEST), byte(JUMPDEST)}
+	cache := NewJumpdestCache(2 * 5)
+	for i, n := range []int64{1, 2, 1, 3, 2} {
+		if bits := cache.bitmap(testHash(n), code); bits.codeSegment(1) || !bits.codeSegment(2) {
+			t.Fatalf("lookup %v: unexpected bitmap %x", i, bits)
+		}
+	}
+	// 2 was evicted by 3, 1 by 2
+	expected := JumpdestCacheStats{Hits: 1, Misses: 4, Evictions: 2, Len: 2, Bytes: 10}
+	if stats := cache.Stats(); stats != expected {
+		t.Errorf("expected %+v, got %+v", expected, stats)
+	}
+	cache.Purge()
+	if stats := cache.Stats(); stats.Len != 0 || stats.Bytes != 0 || stats.Misses != 4 {
+		t.Errorf("expected an empty cache keeping its metrics, got %+v", stats)
+	}
+
+	for _, size := range []int{0, 4} {
+		small := NewJumpdestCache(size)
+		small.bitmap(testHash(1), code)
+		small.bitmap(testHash(1), code)
+		if stats := small.Stats(); stats != (JumpdestCacheStats{Misses: 2}) {
+			t.Errorf("size %v: expected a cache smaller than the bitmap to miss, got %+v", size, stats)
+		}
+	}
+}
+
+func TestVM_JumpdestCache(t *testing.T) {
+	db := NewMemoryDatabase()
+	code, err := Assemble("PUSH 3 JUMP JUMPDEST STOP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetContractCode(testAddress(2), code)
+	// the same code at another address is analysed once
+	db.SetContractCode(testAddress(3), code)
+
+	cache := NewJumpdestCache(1024)
+	var wg sync.WaitGroup
+	for _, to := range []types.Address{testAddress(2), testAddress(3), testAddress(2), testAddress(3)} {
+		wg.Add(1)
+		go func(to types.Address) {
+			defer wg.Done()
+			vm := NewVM(Transaction{From: testAddress(1), To: to, TxType: TxTypeReceive, Depth: 1, Amount: big.NewInt(0)})
+			vm.StateDb = db
+			vm.JumpdestCache = cache
+			if _, err := vm.Query(); err != nil {
+				t.Errorf("query failed, %v", err)
+			}
+		}(to)
+	}
+	wg.Wait()
+	if stats := cache.Stats(); stats.Len != 1 || stats.Hits+stats.Misses != 4 || stats.Misses == 0 {
+		t.Errorf("expected the code to be analysed once, got %+v", stats)
+	}
+}
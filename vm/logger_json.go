@@ -0,0 +1,102 @@
+package vm
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"github.com/vitelabs/go-vite/common/types"
+	"io"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// JSONLogger is a Tracer writing one JSON object per executed opcode and a
+// summary object at the end of each Create or Call, in the style of EIP-3155.
+// Wall-clock time is left out on purpose so traces of the same execution are
+// byte-for-byte identical across builds.
+type JSONLogger struct {
+	encoder *json.Encoder
+	pending *jsonLogStep
+}
+
+type jsonLogStep struct {
+	Pc         uint64   `json:"pc"`
+	Op         OpCode   `json:"op"`
+	Quota      string   `json:"quota"`
+	QuotaCost  string   `json:"quotaCost"`
+	Stack      []string `json:"stack"`
+	MemSize    int      `json:"memSize"`
+	Depth      int      `json:"depth"`
+	ReturnData string   `json:"returnData"`
+	OpName     string   `json:"opName"`
+	Error      string   `json:"error,omitempty"`
+}
+
+type jsonLogSummary struct {
+	Output    string `json:"output"`
+	QuotaUsed string `json:"quotaUsed"`
+	Error     string `json:"error,omitempty"`
+}
+
+// NewJSONLogger returns a JSONLogger writing to w.
+func NewJSONLogger(w io.Writer) *JSONLogger {
+	return &JSONLogger{encoder: json.NewEncoder(w)}
+}
+
+func (l *JSONLogger) CaptureStart(from types.Address, to types.Address, create bool, input []byte, quota uint64, amount *big.Int) {
+}
+
+// CaptureState holds the step back until the next one starts, so that an
+// error raised while executing it can still be attached to its line.
+func (l *JSONLogger) CaptureState(vm *VM, pc uint64, op OpCode, quota, cost uint64, memory []byte, stack []*big.Int, contractAddr types.Address, depth int, err error) {
+	l.flush()
+	step := &jsonLogStep{
+		Pc:         pc,
+		Op:         op,
+		Quota:      hexUint64(quota),
+		QuotaCost:  hexUint64(cost),
+		Stack:      make([]string, len(stack)),
+		MemSize:    len(memory),
+		Depth:      depth,
+		ReturnData: hexBytes(vm.returnData),
+		OpName:     op.String(),
+	}
+	for i, item := range stack {
+		step.Stack[i] = "0x" + item.Text(16)
+	}
+	if err != nil {
+		step.Error = err.Error()
+	}
+	l.pending = step
+}
+
+func (l *JSONLogger) CaptureFault(vm *VM, pc uint64, op OpCode, quota, cost uint64, memory []byte, stack []*big.Int, contractAddr types.Address, depth int, err error) {
+	if l.pending != nil && err != nil {
+		l.pending.Error = err.Error()
+	}
+	l.flush()
+}
+
+func (l *JSONLogger) CaptureEnd(output []byte, quotaUsed uint64, d time.Duration, err error) {
+	l.flush()
+	summary := jsonLogSummary{Output: hexBytes(output), QuotaUsed: hexUint64(quotaUsed)}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+	l.encoder.Encode(summary)
+}
+
+func (l *JSONLogger) flush() {
+	if l.pending != nil {
+		l.encoder.Encode(l.pending)
+		l.pending = nil
+	}
+}
+
+func hexUint64(n uint64) string {
+	return "0x" + strconv.FormatUint(n, 16)
+}
+
+func hexBytes(b []byte) string {
+	return "0x" + hex.EncodeToString(b)
+}
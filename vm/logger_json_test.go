@@ -0,0 +1,40 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/vitelabs/go-vite/common/types"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestJSONLogger(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewJSONLogger(&out)
+	vm := NewVM(Transaction{})
+	vm.StateDb = &testDatabase{}
+	vm.Tracer = logger
+	vm.quotaLeft = 1000000
+	// return 1+2
+	code, _ := hex.DecodeString("6001600201602080919052602090F3")
+	c := newContract(types.Address{}, types.Address{}, types.TokenTypeId{}, new(big.Int), code)
+	c.setCallCode(types.Address{}, types.Hash{}, code)
+	ret, err := run(vm, c)
+	logger.CaptureEnd(ret, 1000000-vm.quotaLeft, 0, err)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 12 {
+		t.Fatalf("expected 12 lines, got %v:\n%v", len(lines), out.String())
+	}
+	expected := []string{
+		`{"pc":0,"op":96,"quota":"0xf4240","quotaCost":"0x3","stack":[],"memSize":0,"depth":1,"returnData":"0x","opName":"PUSH1"}`,
+		`{"pc":4,"op":1,"quota":"0xf423a","quotaCost":"0x3","stack":["0x1","0x2"],"memSize":0,"depth":1,"returnData":"0x","opName":"ADD"}`,
+		`{"output":"0x0000000000000000000000000000000000000000000000000000000000000003","quotaUsed":"0x24"}`,
+	}
+	for i, line := range []string{lines[0], lines[2], lines[11]} {
+		if expected[i] != line {
+			t.Fatalf("expected %v, got %v", expected[i], line)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package vm
 
 import (
 	"encoding/hex"
-	"math/big"
 	"strconv"
 )
 
@@ -72,16 +71,14 @@ func (m *memory) set(offset, size uint64, value []byte) {
 
 // set32 sets the 32 bytes starting at offset to the amount of val, left-padded with zeroes to
 // 32 bytes.
-func (m *memory) set32(offset uint64, val *big.Int) {
+func (m *memory) set32(offset uint64, val *word) {
 	// length of store may never be less than offset + size.
 	// The store should be resized PRIOR to setting the memory
 	if offset+32 > uint64(len(m.store)) {
 		panic("invalid memory: store empty")
 	}
-	// Zero the memory area
-	copy(m.store[offset:offset+32], []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
-	// Fill in relevant bits
-	ReadBits(val, m.store[offset:offset+32])
+	b := val.bytes32()
+	copy(m.store[offset:offset+32], b[:])
 }
 
 func (m *memory) string() string {
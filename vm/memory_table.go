@@ -1,56 +1,73 @@
 package vm
 
-import (
-	"math/big"
-)
-
-func memoryBlake2b(stack *stack) *big.Int {
+func memoryBlake2b(stack *stack) (uint64, bool) {
 	return calcMemSize(stack.back(0), stack.back(1))
 }
 
-func memoryCallDataCopy(stack *stack) *big.Int {
+func memoryCallDataCopy(stack *stack) (uint64, bool) {
 	return calcMemSize(stack.back(0), stack.back(2))
 }
 
-func memoryCodeCopy(stack *stack) *big.Int {
+func memoryCodeCopy(stack *stack) (uint64, bool) {
 	return calcMemSize(stack.back(0), stack.back(2))
 }
 
-func memoryExtCodeCopy(stack *stack) *big.Int {
+func memoryExtCodeCopy(stack *stack) (uint64, bool) {
 	return calcMemSize(stack.back(1), stack.back(3))
 }
 
-func memoryReturnDataCopy(stack *stack) *big.Int {
+func memoryReturnDataCopy(stack *stack) (uint64, bool) {
 	return calcMemSize(stack.back(0), stack.back(2))
 }
 
-func memoryMLoad(stack *stack) *big.Int {
-	return calcMemSize(stack.back(0), big.NewInt(32))
+func memoryMLoad(stack *stack) (uint64, bool) {
+	return calcMemSizeUint64(stack.back(0), 32)
 }
 
-func memoryMStore(stack *stack) *big.Int {
-	return calcMemSize(stack.back(0), big.NewInt(32))
+func memoryMStore(stack *stack) (uint64, bool) {
+	return calcMemSizeUint64(stack.back(0), 32)
 }
 
-func memoryMStore8(stack *stack) *big.Int {
-	return calcMemSize(stack.back(0), big.NewInt(1))
+func memoryMStore8(stack *stack) (uint64, bool) {
+	return calcMemSizeUint64(stack.back(0), 1)
 }
 
-func memoryLog(stack *stack) *big.Int {
+func memoryLog(stack *stack) (uint64, bool) {
 	mSize, mStart := stack.back(1), stack.back(0)
 	return calcMemSize(mStart, mSize)
 }
 
-func memoryDelegateCall(stack *stack) *big.Int {
-	x := calcMemSize(stack.back(3), stack.back(4))
-	y := calcMemSize(stack.back(1), stack.back(2))
-	return BigMax(x, y)
+func memoryCall(stack *stack) (uint64, bool) {
+	return calcMemSize(stack.back(3), stack.back(4))
+}
+
+func memoryCreate(stack *stack) (uint64, bool) {
+	return calcMemSize(stack.back(2), stack.back(3))
+}
+
+func memoryDelegateCall(stack *stack) (uint64, bool) {
+	x, overflow := calcMemSize(stack.back(3), stack.back(4))
+	if overflow {
+		return 0, true
+	}
+	y, overflow := calcMemSize(stack.back(1), stack.back(2))
+	if overflow {
+		return 0, true
+	}
+	if x > y {
+		return x, false
+	}
+	return y, false
+}
+
+func memoryStaticCall(stack *stack) (uint64, bool) {
+	return memoryDelegateCall(stack)
 }
 
-func memoryReturn(stack *stack) *big.Int {
+func memoryReturn(stack *stack) (uint64, bool) {
 	return calcMemSize(stack.back(0), stack.back(1))
 }
 
-func memoryRevert(stack *stack) *big.Int {
+func memoryRevert(stack *stack) (uint64, bool) {
 	return calcMemSize(stack.back(0), stack.back(1))
 }
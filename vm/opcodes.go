@@ -2,11 +2,11 @@ package vm
 
 import "fmt"
 
-// opCode is an VM opcode
-type opCode byte
+// OpCode is an VM opcode
+type OpCode byte
 
 // isPush specifies if an opcode is a PUSH opcode.
-func (op opCode) isPush() bool {
+func (op OpCode) isPush() bool {
 	switch op {
 	case PUSH1, PUSH2, PUSH3, PUSH4, PUSH5, PUSH6, PUSH7, PUSH8, PUSH9, PUSH10, PUSH11, PUSH12, PUSH13, PUSH14, PUSH15, PUSH16, PUSH17, PUSH18, PUSH19, PUSH20, PUSH21, PUSH22, PUSH23, PUSH24, PUSH25, PUSH26, PUSH27, PUSH28, PUSH29, PUSH30, PUSH31, PUSH32:
 		return true
@@ -15,13 +15,13 @@ func (op opCode) isPush() bool {
 }
 
 // isStaticJump specifies if an opcode is JUMP.
-func (op opCode) isStaticJump() bool {
+func (op OpCode) isStaticJump() bool {
 	return op == JUMP
 }
 
 // 0x0 range - arithmetic ops.
 const (
-	STOP opCode = iota
+	STOP OpCode = iota
 	ADD
 	MUL
 	SUB
@@ -37,7 +37,7 @@ const (
 
 // 0x10 range - comparison ops.
 const (
-	LT opCode = iota + 0x10
+	LT OpCode = iota + 0x10
 	GT
 	SLT
 	SGT
@@ -54,12 +54,12 @@ const (
 )
 
 const (
-	BLAKE2B opCode = 0x20 + iota
+	BLAKE2B OpCode = 0x20 + iota
 )
 
 // 0x30 range - closure state.
 const (
-	ADDRESS opCode = 0x30 + iota
+	ADDRESS OpCode = 0x30 + iota
 	BALANCE
 	ORIGIN
 	CALLER
@@ -79,7 +79,7 @@ const (
 
 // 0x40 range - block operations.
 const (
-	BLOCKHASH opCode = 0x40 + iota
+	BLOCKHASH OpCode = 0x40 + iota
 	COINBASE
 	TIMESTAMP
 	NUMBER
@@ -89,7 +89,7 @@ const (
 
 // 0x50 range - 'storage' and execution.
 const (
-	POP opCode = 0x50 + iota
+	POP OpCode = 0x50 + iota
 	MLOAD
 	MSTORE
 	MSTORE8
@@ -105,7 +105,7 @@ const (
 
 // 0x60 range.
 const (
-	PUSH1 opCode = 0x60 + iota
+	PUSH1 OpCode = 0x60 + iota
 	PUSH2
 	PUSH3
 	PUSH4
@@ -173,7 +173,7 @@ const (
 
 // 0xa0 range - logging ops.
 const (
-	LOG0 opCode = 0xa0 + iota
+	LOG0 OpCode = 0xa0 + iota
 	LOG1
 	LOG2
 	LOG3
@@ -182,11 +182,12 @@ const (
 
 // 0xf0 range - closures.
 const (
-	CREATE opCode = 0xf0 + iota
+	CREATE OpCode = 0xf0 + iota
 	CALL
 	CALLCODE
 	RETURN
 	DELEGATECALL
+	CREATE2    = 0xf5
 	STATICCALL = 0xfa
 
 	REVERT       = 0xfd
@@ -194,7 +195,7 @@ const (
 )
 
 // Since the opcodes aren't all in order we can't use a regular slice.
-var opCodeToString = map[opCode]string{
+var opCodeToString = map[OpCode]string{
 	// 0x0 range - arithmetic ops.
 	STOP:       "STOP",
 	ADD:        "ADD",
@@ -349,12 +350,13 @@ var opCodeToString = map[opCode]string{
 	RETURN:       "RETURN",
 	CALLCODE:     "CALLCODE",
 	DELEGATECALL: "DELEGATECALL",
+	CREATE2:      "CREATE2",
 	STATICCALL:   "STATICCALL",
 	REVERT:       "REVERT",
 	SELFDESTRUCT: "SELFDESTRUCT",
 }
 
-func (op opCode) String() string {
+func (op OpCode) String() string {
 	str := opCodeToString[op]
 	if len(str) == 0 {
 		return fmt.Sprintf("Missing opcode 0x%x", int(op))
@@ -363,7 +365,7 @@ func (op opCode) String() string {
 	return str
 }
 
-var stringToOp = map[string]opCode{
+var stringToOp = map[string]OpCode{
 	"STOP":           STOP,
 	"ADD":            ADD,
 	"MUL":            MUL,
@@ -497,6 +499,7 @@ var stringToOp = map[string]opCode{
 	"LOG3":           LOG3,
 	"LOG4":           LOG4,
 	"CREATE":         CREATE,
+	"CREATE2":        CREATE2,
 	"CALL":           CALL,
 	"RETURN":         RETURN,
 	"CALLCODE":       CALLCODE,
@@ -505,6 +508,6 @@ var stringToOp = map[string]opCode{
 }
 
 // StringToOp finds the opcode whose name is stored in `str`.
-func StringToOp(str string) opCode {
+func StringToOp(str string) OpCode {
 	return stringToOp[str]
 }
@@ -33,30 +33,33 @@ const (
 	blake2bWordGas  uint64 = 6     // Once per word of the Blake2b operation's data.
 	sstoreSetGas    uint64 = 20000 // Once per SSTORE operation
 	sstoreResetGas  uint64 = 5000  // Once per SSTORE operation if the zeroness changes from zero.
-	sstoreClearGas  uint64 = 5000  // Once per SSTORE operation if the zeroness doesn't change.
 	sstoreRefundGas uint64 = 15000 // Once per SSTORE operation if the zeroness changes to zero.
+	sstoreDirtyGas  uint64 = 200   // Once per SSTORE operation of a slot changed before in the transaction or keeping its value.
 	jumpdestGas     uint64 = 1     // Jumpdest gas cost.
 	//EpochDuration    uint64 = 30000 // Duration between proof-of-work epochs.
-	callGas         uint64 = 700  // Once per CALL operation & message call transaction.
-	contractCodeGas uint64 = 200  // Per byte in contract code
-	callCreateDepth uint64 = 1024 // Maximum Depth of call/create stack.
-	copyGas         uint64 = 3    //
-	stackLimit      uint64 = 1024 // Maximum size of VM stack allowed.
+	callGas         uint64 = 700      // Once per CALL operation & message call transaction.
+	createGas       uint64 = 32000    // Once per CREATE and CREATE2 operation.
+	contractCodeGas uint64 = 200      // Per byte in contract code
+	callCreateDepth uint64 = 1024     // Maximum Depth of call/create stack.
+	queryQuota      uint64 = 10000000 // Quota available to a read-only query.
+	copyGas         uint64 = 3        //
+	stackLimit      uint64 = 1024     // Maximum size of VM stack allowed.
 	//TierStepGas      uint64 = 0     // Once per operation, for a selection of them.
 	//SuicideRefundGas uint64 = 24000 // Refunded following a suicide operation.
 	memoryGas uint64 = 3 // Times the address of the (highest referenced byte in memory + 1). NOTE: referencing happens on read, write and in instructions such as RETURN and CALL.
 	//
 	//MaxCodeSize = 24576 // Maximum bytecode to permit for a contract
 	//
-	//// Precompiled contract gas prices
-	//
-	//EcrecoverGas            uint64 = 3000   // Elliptic curve sender recovery gas price
-	//Sha256BaseGas           uint64 = 60     // Base price for a SHA256 operation
-	//Sha256PerWordGas        uint64 = 12     // Per-word price for a SHA256 operation
+	// Precompiled contract gas prices
+
+	ed25519VerifyGas        uint64 = 3000 // Base price for an Ed25519 signature verification
+	ed25519VerifyPerWordGas uint64 = 6    // Per-word price for hashing the signed message
+	sha256BaseGas           uint64 = 60   // Base price for a SHA256 operation
+	sha256PerWordGas        uint64 = 12   // Per-word price for a SHA256 operation
 	//Ripemd160BaseGas        uint64 = 600    // Base price for a RIPEMD160 operation
 	//Ripemd160PerWordGas     uint64 = 120    // Per-word price for a RIPEMD160 operation
-	//IdentityBaseGas         uint64 = 15     // Base price for a data copy operation
-	//IdentityPerWordGas      uint64 = 3      // Per-work price for a data copy operation
+	identityBaseGas    uint64 = 15 // Base price for a data copy operation
+	identityPerWordGas uint64 = 3  // Per-word price for a data copy operation
 	//ModExpQuadCoeffDiv      uint64 = 20     // Divisor for the quadratic particle of the big int modular exponentiation
 	//Bn256AddGas             uint64 = 500    // Gas needed for an elliptic curve addition
 	//Bn256ScalarMulGas       uint64 = 40000  // Gas needed for an elliptic curve scalar multiplication
@@ -0,0 +1,127 @@
+package vm
+
+import (
+	"crypto/sha256"
+	"errors"
+	"github.com/vitelabs/go-vite/common/types"
+	"github.com/vitelabs/go-vite/crypto"
+	"github.com/vitelabs/go-vite/crypto/ed25519"
+)
+
+// precompiledContract is a contract implemented natively at a reserved
+// address, reachable with DELEGATECALL and STATICCALL.
+type precompiledContract interface {
+	requiredQuota(s *QuotaSchedule, input []byte) uint64
+	run(input []byte) ([]byte, error)
+}
+
+var (
+	ed25519VerifyAddress = precompiledContractAddress(1)
+	blake2bAddress       = precompiledContractAddress(2)
+	sha256Address        = precompiledContractAddress(3)
+	identityAddress      = precompiledContractAddress(4)
+
+	simplePrecompiledContracts = map[types.Address]precompiledContract{
+		ed25519VerifyAddress: &ed25519Verify{},
+		blake2bAddress:       &blake2bHash{},
+		sha256Address:        &sha256Hash{},
+		identityAddress:      &dataCopy{},
+	}
+
+	errBlake2bSize = errors.New("blake2b output size must be between 1 and 64 bytes")
+)
+
+// precompiledContractAddress returns the address reserved for the n-th
+// precompiled contract, 0x00..0n.
+func precompiledContractAddress(n byte) types.Address {
+	addr, _ := types.BytesToAddress(leftPadBytes([]byte{n}, types.AddressSize))
+	return addr
+}
+
+// runPrecompiledContract charges the quota required by p and runs it.
+func runPrecompiledContract(vm *VM, p precompiledContract, input []byte) ([]byte, error) {
+	if err := vm.useQuota(p.requiredQuota(vm.quotaSchedule, input)); err != nil {
+		return nil, err
+	}
+	return p.run(input)
+}
+
+// wordsQuota returns base plus perWord for every started 32-byte word of
+// input, saturating at the maximum uint64.
+func wordsQuota(input []byte, base, perWord uint64) uint64 {
+	quota, overflow := SafeMul(toWordSize(uint64(len(input))), perWord)
+	if !overflow {
+		quota, overflow = SafeAdd(quota, base)
+	}
+	if overflow {
+		return maxUint64
+	}
+	return quota
+}
+
+// ed25519Verify verifies an Ed25519 signature as used by Vite accounts. The
+// input is the 32-byte public key, the 64-byte signature and the message, the
+// output a word holding 1 if the signature is valid and 0 otherwise.
+type ed25519Verify struct{}
+
+func (c *ed25519Verify) requiredQuota(s *QuotaSchedule, input []byte) uint64 {
+	return wordsQuota(input, s.Ed25519Verify, s.Ed25519VerifyWord)
+}
+
+func (c *ed25519Verify) run(input []byte) ([]byte, error) {
+	result := make([]byte, 32)
+	if len(input) < ed25519.PublicKeySize+ed25519.SignatureSize {
+		return result, nil
+	}
+	pub := ed25519.PublicKey(input[:ed25519.PublicKeySize])
+	sig := input[ed25519.PublicKeySize : ed25519.PublicKeySize+ed25519.SignatureSize]
+	if ed25519.Verify(pub, input[ed25519.PublicKeySize+ed25519.SignatureSize:], sig) {
+		result[31] = 1
+	}
+	return result, nil
+}
+
+// blake2bHash hashes with BLAKE2b of a custom output size. The input is the
+// size in bytes as a word followed by the data, the output the hash.
+type blake2bHash struct{}
+
+func (c *blake2bHash) requiredQuota(s *QuotaSchedule, input []byte) uint64 {
+	return wordsQuota(input, s.Blake2b, s.Blake2bWord)
+}
+
+func (c *blake2bHash) run(input []byte) ([]byte, error) {
+	var size word
+	size.setBytes(getData(input, 0, 32))
+	n, overflow := size.uint64WithOverflow()
+	if overflow || n == 0 || n > 64 {
+		return nil, errBlake2bSize
+	}
+	var data []byte
+	if len(input) > 32 {
+		data = input[32:]
+	}
+	return crypto.Hash(int(n), data), nil
+}
+
+// sha256Hash hashes the input with SHA-256.
+type sha256Hash struct{}
+
+func (c *sha256Hash) requiredQuota(s *QuotaSchedule, input []byte) uint64 {
+	return wordsQuota(input, s.Sha256, s.Sha256Word)
+}
+
+func (c *sha256Hash) run(input []byte) ([]byte, error) {
+	h := sha256.Sum256(input)
+	return h[:], nil
+}
+
+// dataCopy returns the input.
+type dataCopy struct{}
+
+func (c *dataCopy) requiredQuota(s *QuotaSchedule, input []byte) uint64 {
+	return wordsQuota(input, s.Identity, s.IdentityWord)
+}
+
+func (c *dataCopy) run(input []byte) ([]byte, error) {
+	return append([]byte{}, input...), nil
+}
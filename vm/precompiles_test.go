@@ -0,0 +1,113 @@
+package vm
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/vitelabs/go-vite/common/types"
+	"github.com/vitelabs/go-vite/crypto"
+	"github.com/vitelabs/go-vite/crypto/ed25519"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestPrecompiledContracts(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("hello vite")
+	signed := append(append(append([]byte{}, pub...), ed25519.Sign(priv, msg)...), msg...)
+	tampered := append(append([]byte{}, signed...), '!')
+
+	blake2bInput := func(size byte, data string) []byte {
+		return append(leftPadBytes([]byte{size}, 32), data...)
+	}
+	sha256Abc, _ := hex.DecodeString("ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad")
+
+	tests := []struct {
+		addr   types.Address
+		input  []byte
+		output []byte
+		quota  uint64
+		err    error
+	}{
+		{ed25519VerifyAddress, signed, leftPadBytes([]byte{1}, 32), 3000 + 4*6, nil},
+		{ed25519VerifyAddress, tampered, make([]byte, 32), 3000 + 4*6, nil},
+		{ed25519VerifyAddress, signed[:95], make([]byte, 32), 3000 + 3*6, nil},
+		{blake2bAddress, blake2bInput(32, "abc"), crypto.Hash256([]byte("abc")), 30 + 2*6, nil},
+		{blake2bAddress, blake2bInput(5, "abc"), crypto.Hash(5, []byte("abc")), 30 + 2*6, nil},
+		{blake2bAddress, blake2bInput(64, ""), crypto.Hash(64, nil), 30 + 6, nil},
+		{blake2bAddress, blake2bInput(0, "abc"), nil, 30 + 2*6, errBlake2bSize},
+		{blake2bAddress, blake2bInput(65, "abc"), nil, 30 + 2*6, errBlake2bSize},
+		{blake2bAddress, nil, nil, 30, errBlake2bSize},
+		{sha256Address, []byte("abc"), sha256Abc, 60 + 12, nil},
+		{identityAddress, []byte(strings.Repeat("a", 33)), []byte(strings.Repeat("a", 33)), 15 + 2*3, nil},
+		{identityAddress, nil, []byte{}, 15, nil},
+	}
+	for i, test := range tests {
+		p := simplePrecompiledContracts[test.addr]
+		if quota := p.requiredQuota(DefaultQuotaSchedule(), test.input); quota != test.quota {
+			t.Errorf("test %v: expected quota %v, got %v", i, test.quota, quota)
+		}
+		output, err := p.run(test.input)
+		if err != test.err || !bytes.Equal(output, test.output) {
+			t.Errorf("test %v: expected %x %v, got %x %v", i, test.output, test.err, output, err)
+		}
+	}
+}
+
+func TestVM_PrecompiledContract(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("transfer 10 vite")
+	input := append(append(append([]byte{}, pub...), ed25519.Sign(priv, msg)...), msg...)
+	tampered := append(append([]byte{}, input[:len(input)-1]...), 'X')
+
+	// calls the precompile with the call data, returns the success flag and
+	// the first output word
+	caller := func(op string, addr types.Address) []byte {
+		code, err := Assemble(`
+			CALLDATASIZE PUSH 0 PUSH 0 CALLDATACOPY
+			PUSH 32 PUSH 32 CALLDATASIZE PUSH 0 PUSH 0x` + hex.EncodeToString(addr.Bytes()) + ` ` + op + `
+			PUSH 0 MSTORE
+			PUSH 64 PUSH 0 RETURN
+		`)
+		if err != nil {
+			t.Fatalf("assemble failed, %v", err)
+		}
+		return code
+	}
+	tests := []struct {
+		op     string
+		addr   types.Address
+		input  []byte
+		output []byte
+	}{
+		{"STATICCALL", ed25519VerifyAddress, input, leftPadBytes([]byte{1}, 32)},
+		{"STATICCALL", ed25519VerifyAddress, tampered, make([]byte, 32)},
+		{"DELEGATECALL", ed25519VerifyAddress, input, leftPadBytes([]byte{1}, 32)},
+		{"STATICCALL", sha256Address, []byte("abc"), nil},
+		{"DELEGATECALL", identityAddress, []byte("abc"), rightPadBytes([]byte("abc"), 32)},
+	}
+	for i, test := range tests {
+		db := NewMemoryDatabase()
+		to := testAddress(2)
+		db.SetContractCode(to, caller(test.op, test.addr))
+		vm := NewVM(Transaction{From: testAddress(1), To: to, Depth: 1, TxType: TxTypeReceive, Amount: big.NewInt(0), Data: test.input})
+		vm.StateDb = db
+		result, err := vm.Call()
+		if err != nil {
+			t.Fatalf("test %v: call failed, %v", i, err)
+		}
+		if test.output == nil {
+			test.output, _ = simplePrecompiledContracts[test.addr].run(test.input)
+		}
+		if !bytes.Equal(result.ReturnData, append(leftPadBytes([]byte{1}, 32), test.output...)) {
+			t.Errorf("test %v: expected success and %x, got %x", i, test.output, result.ReturnData)
+		}
+	}
+}
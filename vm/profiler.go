@@ -0,0 +1,202 @@
+package vm
+
+import (
+	"fmt"
+	"github.com/vitelabs/go-vite/common/types"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Profiler aggregates the quota and wall-clock time spent per opcode and per
+// code location over every execution of the VMs it is set on, see
+// VMConfig.Profiler. It is safe for concurrent use by several VMs.
+type Profiler struct {
+	mu        sync.Mutex
+	opcodes   map[OpCode]*ProfileEntry
+	locations map[profileLocation]*ProfileEntry
+	stacks    map[string]uint64 // quota per collapsed call stack
+}
+
+// ProfileEntry is the profile of an opcode or of a code location.
+type ProfileEntry struct {
+	Address     types.Address // Address of the code of a location, empty for an opcode
+	Pc          uint64        // Pc of a location
+	Op          OpCode
+	Count       uint64        // Times executed, including failed executions
+	Quota       uint64        // Quota charged, including MemoryQuota and the quota of precompiled contracts
+	MemoryQuota uint64        // Quota charged for memory expansion
+	Time        time.Duration // Time spent executing, excluding nested executions
+}
+
+type profileLocation struct {
+	address types.Address
+	pc      uint64
+	op      OpCode
+}
+
+// profileFrame is an execution in the call stack of a profiled VM.
+type profileFrame struct {
+	address types.Address // address of the code executing
+
+	// the step in progress
+	pending     bool
+	pc          uint64
+	op          OpCode
+	start       time.Time
+	quotaLeft   uint64        // quota left before the step
+	memoryQuota uint64        // quota charged for memory expansion
+	nested      time.Duration // time spent in executions nested in op
+	nestedQuota uint64        // quota charged by executions nested in op
+}
+
+// startStep starts profiling the opcode op at pc.
+func (f *profileFrame) startStep(pc uint64, op OpCode, quotaLeft uint64) {
+	*f = profileFrame{address: f.address, pending: true, pc: pc, op: op, start: time.Now(), quotaLeft: quotaLeft}
+}
+
+func (f *profileFrame) String() string {
+	return fmt.Sprintf("%v:%v:%v", f.address, f.pc, f.op)
+}
+
+// NewProfiler returns an empty Profiler.
+func NewProfiler() *Profiler {
+	return &Profiler{
+		opcodes:   make(map[OpCode]*ProfileEntry),
+		locations: make(map[profileLocation]*ProfileEntry),
+		stacks:    make(map[string]uint64),
+	}
+}
+
+// endStep records the step in progress in the innermost of frames, if any,
+// with quotaLeft after it. The quota and time of nested executions are left
+// to their own opcodes.
+func (p *Profiler) endStep(frames []*profileFrame, quotaLeft uint64) {
+	frame := frames[len(frames)-1]
+	if !frame.pending {
+		return
+	}
+	frame.pending = false
+	p.add(frames, frame.quotaLeft-quotaLeft-frame.nestedQuota, frame.memoryQuota, time.Since(frame.start)-frame.nested)
+}
+
+// add records the execution of the opcode of the innermost of frames.
+func (p *Profiler) add(frames []*profileFrame, quota, memoryQuota uint64, d time.Duration) {
+	frame := frames[len(frames)-1]
+	labels := make([]string, len(frames))
+	for i, f := range frames {
+		labels[i] = f.String()
+	}
+	stack := strings.Join(labels, ";")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry, ok := p.opcodes[frame.op]
+	if !ok {
+		entry = &ProfileEntry{Op: frame.op}
+		p.opcodes[frame.op] = entry
+	}
+	entry.add(quota, memoryQuota, d)
+	loc := profileLocation{frame.address, frame.pc, frame.op}
+	if entry, ok = p.locations[loc]; !ok {
+		entry = &ProfileEntry{Address: frame.address, Pc: frame.pc, Op: frame.op}
+		p.locations[loc] = entry
+	}
+	entry.add(quota, memoryQuota, d)
+	if quota > memoryQuota {
+		p.stacks[stack] += quota - memoryQuota
+	}
+	if memoryQuota > 0 {
+		p.stacks[stack+";memory"] += memoryQuota
+	}
+}
+
+func (e *ProfileEntry) add(quota, memoryQuota uint64, d time.Duration) {
+	e.Count++
+	e.Quota += quota
+	e.MemoryQuota += memoryQuota
+	e.Time += d
+}
+
+// Opcodes returns the profile of every opcode executed, by descending quota.
+func (p *Profiler) Opcodes() []ProfileEntry {
+	p.mu.Lock()
+	entries := make([]ProfileEntry, 0, len(p.opcodes))
+	for _, entry := range p.opcodes {
+		entries = append(entries, *entry)
+	}
+	p.mu.Unlock()
+	sortProfileEntries(entries)
+	return entries
+}
+
+// Locations returns the profile of every code location executed, by
+// descending quota.
+func (p *Profiler) Locations() []ProfileEntry {
+	p.mu.Lock()
+	entries := make([]ProfileEntry, 0, len(p.locations))
+	for _, entry := range p.locations {
+		entries = append(entries, *entry)
+	}
+	p.mu.Unlock()
+	sortProfileEntries(entries)
+	return entries
+}
+
+func sortProfileEntries(entries []ProfileEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Quota != b.Quota {
+			return a.Quota > b.Quota
+		}
+		if a.Address != b.Address {
+			return a.Address.String() < b.Address.String()
+		}
+		if a.Pc != b.Pc {
+			return a.Pc < b.Pc
+		}
+		return a.Op < b.Op
+	})
+}
+
+// WriteReport writes the profiles of the opcodes and of the code locations as
+// two tables.
+func (p *Profiler) WriteReport(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "opcode\tcount\tquota\tmemory\ttime\t")
+	for _, e := range p.Opcodes() {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t\n", e.Op, e.Count, e.Quota, e.MemoryQuota, e.Time)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(tw, "address\tpc\topcode\tcount\tquota\tmemory\ttime\t")
+	for _, e := range p.Locations() {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t\n", e.Address, e.Pc, e.Op, e.Count, e.Quota, e.MemoryQuota, e.Time)
+	}
+	return tw.Flush()
+}
+
+// WriteCollapsed writes the quota charged per call stack in the collapsed
+// format read by flamegraph.pl and speedscope, one "frame;frame quota" line
+// per stack. Frames are address:pc:opcode, the quota of a memory expansion is
+// in a "memory" frame on top of its opcode.
+func (p *Profiler) WriteCollapsed(w io.Writer) error {
+	p.mu.Lock()
+	stacks := make([]string, 0, len(p.stacks))
+	for stack, quota := range p.stacks {
+		stacks = append(stacks, fmt.Sprintf("%v %v", stack, quota))
+	}
+	p.mu.Unlock()
+	sort.Strings(stacks)
+	for _, line := range stacks {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestProfiler(t *testing.T) {
+	db := NewMemoryDatabase()
+	from, to, callee := testAddress(1), testAddress(2), testAddress(3)
+	code, err := Assemble("PUSH 1 PUSH 0 MSTORE PUSH 0 PUSH 0 PUSH 0 PUSH 0 PUSH 0x" + fmt.Sprintf("%x", callee.Bytes()) + " DELEGATECALL STOP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetContractCode(to, code)
+	calleeCode, _ := Assemble("PUSH 1 PUSH 0 SSTORE")
+	db.SetContractCode(callee, calleeCode)
+
+	profiler := NewProfiler()
+	for i := 0; i < 2; i++ {
+		vm := NewVM(Transaction{From: from, To: to, Depth: 1, TxType: TxTypeReceive, Amount: big.NewInt(0)})
+		vm.StateDb = db
+		vm.Profiler = profiler
+		if _, err := vm.Call(); err != nil {
+			t.Fatalf("call failed, %v", err)
+		}
+	}
+
+	opcodes := make(map[OpCode]ProfileEntry)
+	for _, e := range profiler.Opcodes() {
+		opcodes[e.Op] = e
+	}
+	// the second SSTORE writes the current value
+	if e := opcodes[SSTORE]; e.Count != 2 || e.Quota != 20000+200 || e.MemoryQuota != 0 {
+		t.Errorf("unexpected SSTORE profile %+v", e)
+	}
+	if e := opcodes[MSTORE]; e.Count != 2 || e.Quota != 2*(3+3) || e.MemoryQuota != 2*3 {
+		t.Errorf("unexpected MSTORE profile %+v", e)
+	}
+	if e := opcodes[PUSH1]; e.Count != 2*8 || e.Quota != 2*8*3 {
+		t.Errorf("unexpected PUSH1 profile %+v", e)
+	}
+	if e := profiler.Opcodes()[0]; e.Op != SSTORE {
+		t.Errorf("expected SSTORE to use the most quota, got %v", e.Op)
+	}
+	if e := profiler.Locations()[0]; e.Address != callee || e.Pc != 4 || e.Op != SSTORE {
+		t.Errorf("expected the SSTORE of the callee to use the most quota, got %+v", e)
+	}
+
+	var collapsed bytes.Buffer
+	if err := profiler.WriteCollapsed(&collapsed); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		fmt.Sprintf("%v:4:MSTORE 6", to),
+		fmt.Sprintf("%v:4:MSTORE;memory 6", to),
+		fmt.Sprintf("%v:34:DELEGATECALL;%v:4:SSTORE 20200", to, callee),
+	} {
+		if !strings.Contains(collapsed.String(), line+"\n") {
+			t.Errorf("expected the collapsed stacks to contain %q, got\n%v", line, collapsed.String())
+		}
+	}
+
+	var report bytes.Buffer
+	if err := profiler.WriteReport(&report); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(report.String(), callee.String()) {
+		t.Errorf("expected the report to list the callee, got\n%v", report.String())
+	}
+}
+
+func TestProfiler_FailedAndPrecompiled(t *testing.T) {
+	db := NewMemoryDatabase()
+	from, to := testAddress(1), testAddress(2)
+	// staticcall the identity precompiled contract, then run out of quota
+	code, err := Assemble("PUSH 0 PUSH 0 PUSH 0 PUSH 0 PUSH 4 STATICCALL PUSH 1 PUSH 0 SSTORE STOP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetContractCode(to, code)
+
+	profiler := NewProfiler()
+	vm := NewVM(Transaction{From: from, To: to, Depth: 1, TxType: TxTypeReceive, Amount: big.NewInt(0)})
+	vm.StateDb = db
+	vm.QuotaProvider = FixedQuotaProvider(21000 + 7*3 + 700 + 15 + 100)
+	vm.Profiler = profiler
+	if _, err := vm.Call(); err != ErrOutOfQuota {
+		t.Fatalf("expected %v, got %v", ErrOutOfQuota, err)
+	}
+
+	opcodes := make(map[OpCode]ProfileEntry)
+	for _, e := range profiler.Opcodes() {
+		opcodes[e.Op] = e
+	}
+	if e := opcodes[STATICCALL]; e.Count != 1 || e.Quota != 700+15 {
+		t.Errorf("expected STATICCALL to include the quota of the precompiled contract, got %+v", e)
+	}
+	if e := opcodes[SSTORE]; e.Count != 1 || e.Quota != 0 {
+		t.Errorf("expected the SSTORE out of quota to be counted, got %+v", e)
+	}
+}
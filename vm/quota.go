@@ -0,0 +1,122 @@
+package vm
+
+import (
+	"github.com/vitelabs/go-vite/common/types"
+	"math/big"
+)
+
+// QuotaProvider computes the quota available to a transaction and the service
+// fee charged for creating a contract.
+type QuotaProvider interface {
+	// Quota returns the quota addr can spend executing tx.
+	Quota(addr types.Address, tx *Transaction) uint64
+	// CreateContractFee returns the fee in VITE for deploying a contract with
+	// the given creation data.
+	CreateContractFee(data []byte) *big.Int
+}
+
+// QuotaDatabase provides the account data quota is computed from.
+type QuotaDatabase interface {
+	// GetPledgeAmount returns the amount of VITE pledged for addr.
+	GetPledgeAmount(addr types.Address) *big.Int
+	// GetQuotaUsed returns the quota addr used in the snapshots from
+	// fromHeight to toHeight, both inclusive.
+	GetQuotaUsed(addr types.Address, fromHeight, toHeight uint64) uint64
+}
+
+// QuotaParams configures a PledgeQuotaProvider.
+type QuotaParams struct {
+	PledgeUnit         *big.Int // Amount of pledged VITE granting QuotaPerPledgeUnit
+	QuotaPerPledgeUnit uint64   // Quota granted per PledgeUnit in every regeneration window
+	DifficultyUnit     *big.Int // PoW difficulty granting QuotaPerDifficulty
+	QuotaPerDifficulty uint64   // Quota granted per DifficultyUnit of PoW, for a single transaction
+	RegenerationWindow uint64   // Number of snapshots after which used quota is available again
+	MaxQuota           uint64   // Maximum quota of a single transaction
+
+	CreateContractFeeBase    *big.Int // VITE charged for every contract creation
+	CreateContractFeePerByte *big.Int // VITE charged per byte of contract creation data
+}
+
+var (
+	attoVite = new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+
+	DefaultQuotaParams = QuotaParams{
+		PledgeUnit:         attoVite,
+		QuotaPerPledgeUnit: 21,
+		DifficultyUnit:     big.NewInt(0x3ffffff),
+		QuotaPerDifficulty: txGas,
+		RegenerationWindow: 75,
+		MaxQuota:           1000000,
+
+		CreateContractFeeBase:    new(big.Int).Mul(big.NewInt(10), attoVite),
+		CreateContractFeePerByte: new(big.Int).Div(attoVite, big.NewInt(1000)),
+	}
+)
+
+// PledgeQuotaProvider grants quota for VITE pledged to an account, which
+// regenerates after the quota used in the last RegenerationWindow snapshots,
+// plus a one-off quota for the PoW difficulty attached to the transaction.
+type PledgeQuotaProvider struct {
+	db     QuotaDatabase
+	params QuotaParams
+}
+
+func NewPledgeQuotaProvider(db QuotaDatabase, params QuotaParams) *PledgeQuotaProvider {
+	return &PledgeQuotaProvider{db: db, params: params}
+}
+
+func (p *PledgeQuotaProvider) Quota(addr types.Address, tx *Transaction) uint64 {
+	// pledged quota regenerates, only what was used in the window is taken off
+	quota := unitsQuota(p.db.GetPledgeAmount(addr), p.params.PledgeUnit, p.params.QuotaPerPledgeUnit)
+
+	var snapshotHeight uint64
+	if tx.SnapshotHeight != nil {
+		snapshotHeight = tx.SnapshotHeight.Uint64()
+	}
+	var fromHeight uint64
+	if p.params.RegenerationWindow > 0 && snapshotHeight >= p.params.RegenerationWindow {
+		fromHeight = snapshotHeight - p.params.RegenerationWindow + 1
+	}
+	if used := p.db.GetQuotaUsed(addr, fromHeight, snapshotHeight); used < quota {
+		quota = quota - used
+	} else {
+		quota = 0
+	}
+
+	var overflow bool
+	if quota, overflow = SafeAdd(quota, unitsQuota(tx.Difficulty, p.params.DifficultyUnit, p.params.QuotaPerDifficulty)); overflow {
+		return p.params.MaxQuota
+	}
+	return min(quota, p.params.MaxQuota)
+}
+
+func (p *PledgeQuotaProvider) CreateContractFee(data []byte) *big.Int {
+	fee := new(big.Int).Mul(p.params.CreateContractFeePerByte, big.NewInt(int64(len(data))))
+	return fee.Add(fee, p.params.CreateContractFeeBase)
+}
+
+// unitsQuota returns quotaPerUnit for every whole unit in amount, saturating
+// at the maximum uint64.
+func unitsQuota(amount, unit *big.Int, quotaPerUnit uint64) uint64 {
+	if amount == nil || unit == nil || unit.Sign() <= 0 || amount.Sign() <= 0 {
+		return 0
+	}
+	quota := new(big.Int).Div(amount, unit)
+	quota.Mul(quota, new(big.Int).SetUint64(quotaPerUnit))
+	if q, overflow := bigUint64(quota); !overflow {
+		return q
+	}
+	return maxUint64
+}
+
+// FixedQuotaProvider grants the same quota to every transaction and charges
+// no contract creation fee. It is used when a VM has no QuotaProvider.
+type FixedQuotaProvider uint64
+
+func (q FixedQuotaProvider) Quota(addr types.Address, tx *Transaction) uint64 {
+	return uint64(q)
+}
+
+func (q FixedQuotaProvider) CreateContractFee(data []byte) *big.Int {
+	return new(big.Int)
+}
@@ -0,0 +1,170 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// QuotaSchedule holds the quota charged for executing transactions, opcodes
+// and precompiled contracts.
+type QuotaSchedule struct {
+	QuickStep   uint64 `json:"quickStep"`   // ADDRESS, CALLER, POP, PC, MSIZE, ...
+	FastestStep uint64 `json:"fastestStep"` // ADD, SUB, LT, ..., PUSH, DUP, SWAP, MLOAD, MSTORE
+	FastStep    uint64 `json:"fastStep"`    // MUL, DIV, MOD, ...
+	MidStep     uint64 `json:"midStep"`     // ADDMOD, MULMOD, JUMP
+	SlowStep    uint64 `json:"slowStep"`    // JUMPI, EXP
+	ExtStep     uint64 `json:"extStep"`     // BLOCKHASH
+	ExtCodeSize uint64 `json:"extCodeSize"`
+	ExtCodeCopy uint64 `json:"extCodeCopy"`
+	ExtCodeHash uint64 `json:"extCodeHash"`
+	Balance     uint64 `json:"balance"`
+	SLoad       uint64 `json:"sload"`
+	ExpByte     uint64 `json:"expByte"` // Per byte of the EXP exponent
+	Jumpdest    uint64 `json:"jumpdest"`
+	Copy        uint64 `json:"copy"` // Per word copied by *COPY
+
+	Memory       uint64 `json:"memory"`       // Per word of memory
+	QuadCoeffDiv uint64 `json:"quadCoeffDiv"` // Divisor of the quadratic memory cost, must not be zero
+
+	Tx                 uint64 `json:"tx"`                 // Per transaction not creating a contract
+	TxContractCreation uint64 `json:"txContractCreation"` // Per transaction creating a contract
+	TxDataZero         uint64 `json:"txDataZero"`         // Per zero byte of transaction data
+	TxDataNonZero      uint64 `json:"txDataNonZero"`      // Per non-zero byte of transaction data
+	ContractCode       uint64 `json:"contractCode"`       // Per byte of created contract code
+
+	Log      uint64 `json:"log"`
+	LogTopic uint64 `json:"logTopic"`
+	LogData  uint64 `json:"logData"` // Per byte of log data
+
+	Blake2b     uint64 `json:"blake2b"`
+	Blake2bWord uint64 `json:"blake2bWord"` // Per word hashed by BLAKE2B and CREATE2
+
+	SStoreSet    uint64 `json:"sstoreSet"`    // First SSTORE of a non-zero value to a slot zero at the start of the transaction
+	SStoreReset  uint64 `json:"sstoreReset"`  // First SSTORE to a slot non-zero at the start of the transaction
+	SStoreRefund uint64 `json:"sstoreRefund"` // Refunded for clearing a slot
+	SStoreDirty  uint64 `json:"sstoreDirty"`  // SSTORE of a slot changed before in the transaction or of its current value
+
+	Call   uint64 `json:"call"` // CALL, DELEGATECALL and STATICCALL
+	Create uint64 `json:"create"`
+
+	Ed25519Verify     uint64 `json:"ed25519Verify"`
+	Ed25519VerifyWord uint64 `json:"ed25519VerifyWord"`
+	Sha256            uint64 `json:"sha256"`
+	Sha256Word        uint64 `json:"sha256Word"`
+	Identity          uint64 `json:"identity"`
+	IdentityWord      uint64 `json:"identityWord"`
+}
+
+// defaultQuotaSchedule is the quota schedule in effect until the ChainConfig
+// activates another one. It is shared by every VM and never handed out, use
+// DefaultQuotaSchedule for a copy.
+var defaultQuotaSchedule = QuotaSchedule{
+	QuickStep:   quickStepGas,
+	FastestStep: fastestStepGas,
+	FastStep:    fastStepGas,
+	MidStep:     midStepGas,
+	SlowStep:    slowStepGas,
+	ExtStep:     extStepGas,
+	ExtCodeSize: extCodeSizeGas,
+	ExtCodeCopy: extCodeCopyGas,
+	ExtCodeHash: extCodeHashGas,
+	Balance:     balanceGas,
+	SLoad:       sLoadGas,
+	ExpByte:     expByteGas,
+	Jumpdest:    jumpdestGas,
+	Copy:        copyGas,
+
+	Memory:       memoryGas,
+	QuadCoeffDiv: quadCoeffDiv,
+
+	Tx:                 txGas,
+	TxContractCreation: txGasContractCreation,
+	TxDataZero:         txDataZeroGas,
+	TxDataNonZero:      txDataNonZeroGas,
+	ContractCode:       contractCodeGas,
+
+	Log:      logGas,
+	LogTopic: logTopicGas,
+	LogData:  logDataGas,
+
+	Blake2b:     blake2bGas,
+	Blake2bWord: blake2bWordGas,
+
+	SStoreSet:    sstoreSetGas,
+	SStoreReset:  sstoreResetGas,
+	SStoreRefund: sstoreRefundGas,
+	SStoreDirty:  sstoreDirtyGas,
+
+	Call:   callGas,
+	Create: createGas,
+
+	Ed25519Verify:     ed25519VerifyGas,
+	Ed25519VerifyWord: ed25519VerifyPerWordGas,
+	Sha256:            sha256BaseGas,
+	Sha256Word:        sha256PerWordGas,
+	Identity:          identityBaseGas,
+	IdentityWord:      identityPerWordGas,
+}
+
+// DefaultQuotaSchedule returns a copy of the quota schedule in effect until the
+// ChainConfig activates another one, to be repriced for a QuotaScheduleUpgrade.
+func DefaultQuotaSchedule() *QuotaSchedule {
+	s := defaultQuotaSchedule
+	return &s
+}
+
+var errQuadCoeffDiv = errors.New("quadCoeffDiv must not be zero")
+
+// LoadQuotaSchedule reads a quota schedule from a JSON file, or a TOML file
+// if its name ends in .toml. Both use the keys of the JSON encoding at the top
+// level. Costs left out keep their value in the default schedule, unknown keys
+// are an error.
+func LoadQuotaSchedule(file string) (*QuotaSchedule, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(file), ".toml") {
+		if data, err = tomlToJSON(data); err != nil {
+			return nil, fmt.Errorf("%v: %v", file, err)
+		}
+	}
+	s := new(QuotaSchedule)
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("%v: %v", file, err)
+	}
+	return s, nil
+}
+
+// UnmarshalJSON decodes a quota schedule, costs left out keep their value in
+// the default schedule and unknown keys are an error.
+func (s *QuotaSchedule) UnmarshalJSON(data []byte) error {
+	type quotaSchedule QuotaSchedule // without UnmarshalJSON
+	v := quotaSchedule(defaultQuotaSchedule)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	if v.QuadCoeffDiv == 0 {
+		return errQuadCoeffDiv
+	}
+	*s = QuotaSchedule(v)
+	return nil
+}
+
+// tomlToJSON converts a TOML document to JSON, to be decoded and validated
+// like the JSON encoding.
+func tomlToJSON(data []byte) ([]byte, error) {
+	var values map[string]interface{}
+	if _, err := toml.Decode(string(data), &values); err != nil {
+		return nil, err
+	}
+	return json.Marshal(values)
+}
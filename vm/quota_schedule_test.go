@@ -0,0 +1,95 @@
+package vm
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadQuotaSchedule(t *testing.T) {
+	dir, err := ioutil.TempDir("", "quota_schedule")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	expected := *DefaultQuotaSchedule()
+	expected.FastestStep = 4
+	expected.SStoreSet = 30000
+
+	tests := []struct {
+		file string
+		data string
+		err  string
+	}{
+		{"schedule.json", `{"fastestStep": 4, "sstoreSet": 30000}`, ""},
+		{"schedule.toml", "# repriced\nfastestStep = 0x4\n\n\"sstoreSet\" = +30_000 # was 20000\n", ""},
+		{"unknown.json", `{"fastestStep": 4, "sstorSet": 30000}`, `json: unknown field "sstorSet"`},
+		{"unknown.toml", "sstorSet = 30000", `json: unknown field "sstorSet"`},
+		{"quoted.toml", `"sstore#Set" = 30000`, `json: unknown field "sstore#Set"`},
+		{"table.toml", "[schedule]\nfastestStep = 4", `json: unknown field "schedule"`},
+		{"negative.toml", "fastestStep = 4\nsstoreSet = -1", "cannot unmarshal number -1 into Go struct field quotaSchedule.sstoreSet of type uint64"},
+		{"float.toml", "fastestStep = 4.5", "cannot unmarshal number 4.5 into Go struct field quotaSchedule.fastestStep of type uint64"},
+		{"twice.toml", "fastestStep = 4\nfastestStep = 5", "Key 'fastestStep' has already been defined."},
+		{"quad.json", `{"quadCoeffDiv": 0}`, "quadCoeffDiv must not be zero"},
+	}
+	for _, test := range tests {
+		file := filepath.Join(dir, test.file)
+		if err := ioutil.WriteFile(file, []byte(test.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		s, err := LoadQuotaSchedule(file)
+		if test.err != "" {
+			if err == nil || !strings.HasSuffix(err.Error(), test.err) {
+				t.Errorf("%v: expected error %q, got %v", test.file, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: load failed, %v", test.file, err)
+		} else if *s != expected {
+			t.Errorf("%v: expected %+v, got %+v", test.file, expected, *s)
+		}
+	}
+}
+
+func TestVM_QuotaSchedule(t *testing.T) {
+	// sstore(0, 1 + 2)
+	code, _ := hex.DecodeString("600260010160005500")
+	call := func(config *ChainConfig) uint64 {
+		db := NewMemoryDatabase()
+		db.SetContractCode(testAddress(2), code)
+		vm := NewVMWithChainConfig(Transaction{From: testAddress(1), To: testAddress(2), TxType: TxTypeReceive, Depth: 1, Amount: big.NewInt(0)}, config)
+		vm.StateDb = db
+		result, err := vm.Call()
+		if err != nil {
+			t.Fatalf("call failed, %v", err)
+		}
+		return result.QuotaUsed
+	}
+
+	if quotaUsed := call(DefaultChainConfig); quotaUsed != 21000+4*3+20000 {
+		t.Fatalf("expected the default schedule to charge %v, got %v", 21000+4*3+20000, quotaUsed)
+	}
+	s := *DefaultQuotaSchedule()
+	s.Tx, s.FastestStep, s.SStoreSet = 1000, 10, 500
+	if quotaUsed := call(&ChainConfig{LeafHeight: big.NewInt(0), QuotaSchedules: []QuotaScheduleUpgrade{{big.NewInt(0), &s}}}); quotaUsed != 1000+4*10+500 {
+		t.Fatalf("expected the repriced schedule to charge %v, got %v", 1000+4*10+500, quotaUsed)
+	}
+}
+
+func TestIntrinsicGasCost_FreeData(t *testing.T) {
+	s := *DefaultQuotaSchedule()
+	s.TxDataZero = 0
+	if cost, err := intrinsicGasCost(&s, []byte{0, 1}, false); err != nil || cost != txGas+txDataNonZeroGas {
+		t.Fatalf("expected %v, got %v %v", txGas+txDataNonZeroGas, cost, err)
+	}
+	s.TxDataNonZero = 0
+	if cost, err := intrinsicGasCost(&s, []byte{0, 1}, true); err != nil || cost != txGasContractCreation {
+		t.Fatalf("expected %v, got %v %v", txGasContractCreation, cost, err)
+	}
+}
@@ -0,0 +1,58 @@
+package vm
+
+import (
+	"github.com/vitelabs/go-vite/common/types"
+	"math/big"
+	"testing"
+)
+
+type testQuotaDatabase struct {
+	pledge   *big.Int
+	used     uint64
+	from, to uint64
+}
+
+func (db *testQuotaDatabase) GetPledgeAmount(addr types.Address) *big.Int {
+	return db.pledge
+}
+
+func (db *testQuotaDatabase) GetQuotaUsed(addr types.Address, fromHeight, toHeight uint64) uint64 {
+	db.from, db.to = fromHeight, toHeight
+	return db.used
+}
+
+func TestPledgeQuotaProvider(t *testing.T) {
+	vite := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), attoVite) }
+	tests := []struct {
+		pledge         *big.Int
+		used           uint64
+		difficulty     *big.Int
+		snapshotHeight int64
+		quota          uint64
+		from           uint64
+	}{
+		{vite(0), 0, nil, 1, 0, 0},
+		{vite(1000), 0, nil, 1, 21000, 0},
+		{vite(1000), 1000, nil, 100, 20000, 26},
+		{vite(1000), 30000, nil, 100, 0, 26},
+		{vite(1000), 30000, big.NewInt(0x3ffffff), 100, 21000, 26},
+		{vite(1000), 1000, big.NewInt(0x7fffffe), 74, 62000, 0},
+		{vite(1000000), 0, nil, 1, 1000000, 0},
+	}
+	for i, test := range tests {
+		db := &testQuotaDatabase{pledge: test.pledge, used: test.used}
+		p := NewPledgeQuotaProvider(db, DefaultQuotaParams)
+		quota := p.Quota(types.Address{}, &Transaction{Difficulty: test.difficulty, SnapshotHeight: big.NewInt(test.snapshotHeight)})
+		if quota != test.quota {
+			t.Fatalf("test %v: expected quota %v, got %v", i, test.quota, quota)
+		}
+		if db.from != test.from || db.to != uint64(test.snapshotHeight) {
+			t.Fatalf("test %v: expected window [%v, %v], got [%v, %v]", i, test.from, test.snapshotHeight, db.from, db.to)
+		}
+	}
+
+	fee := NewPledgeQuotaProvider(&testQuotaDatabase{}, DefaultQuotaParams).CreateContractFee(make([]byte, 1000))
+	if fee.Cmp(vite(11)) != 0 {
+		t.Fatalf("expected fee %v, got %v", vite(11), fee)
+	}
+}
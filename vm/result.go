@@ -0,0 +1,186 @@
+package vm
+
+import (
+	"encoding/json"
+	"github.com/vitelabs/go-vite/common/types"
+)
+
+// FailureKind categorizes why an execution failed.
+type FailureKind int
+
+const (
+	FailureNone                FailureKind = iota
+	FailureOutOfQuota                      // ErrOutOfQuota, the transaction can be retried with more quota
+	FailureReverted                        // ErrExecutionReverted, RevertData holds the data passed to REVERT
+	FailureInsufficientBalance             // ErrInsufficientBalance
+	FailureDepth                           // ErrDepth
+	FailureAddressCollision                // ErrContractAddressCreationFail
+	FailureWriteProtection                 // ErrWriteProtection
+	FailureInvalid                         // Invalid code: bad opcode or jump, stack under- or overflow, ...
+	FailureInternal                        // ErrVMInternal, a bug of the VM
+	FailureAborted                         // ErrExecutionAborted, VMConfig.Context is done or MaxSteps reached
+)
+
+var failureKindStrings = [...]string{
+	FailureNone:                "",
+	FailureOutOfQuota:          "outOfQuota",
+	FailureReverted:            "reverted",
+	FailureInsufficientBalance: "insufficientBalance",
+	FailureDepth:               "depth",
+	FailureAddressCollision:    "addressCollision",
+	FailureWriteProtection:     "writeProtection",
+	FailureInvalid:             "invalid",
+	FailureInternal:            "internal",
+	FailureAborted:             "aborted",
+}
+
+func (k FailureKind) String() string {
+	if k < 0 || int(k) >= len(failureKindStrings) {
+		return "unknown"
+	}
+	return failureKindStrings[k]
+}
+
+func (k FailureKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// failureKind returns the FailureKind of an error returned by the VM.
+func failureKind(err error) FailureKind {
+	switch err {
+	case nil:
+		return FailureNone
+	case ErrOutOfQuota:
+		return FailureOutOfQuota
+	case ErrExecutionReverted:
+		return FailureReverted
+	case ErrInsufficientBalance:
+		return FailureInsufficientBalance
+	case ErrDepth:
+		return FailureDepth
+	case ErrContractAddressCreationFail:
+		return FailureAddressCollision
+	case ErrWriteProtection:
+		return FailureWriteProtection
+	case ErrExecutionAborted:
+		return FailureAborted
+	}
+	if _, ok := err.(*InternalError); ok {
+		return FailureInternal
+	}
+	return FailureInvalid
+}
+
+// ExecutionResult is the outcome of VM.Create and VM.Call.
+type ExecutionResult struct {
+	ReturnData      []byte         // Data returned by the contract, for a creation the deployed code
+	QuotaUsed       uint64         // Quota charged, all of it on ErrOutOfQuota
+	QuotaRefunded   uint64         // Part of the quota refunded for clearing storage
+	Logs            []*Log         // Logs emitted
+	Txs             []*Transaction // Send transactions emitted, including refunds
+	ContractAddress types.Address  // Address of the created contract, empty unless a creation succeeded
+	RevertData      []byte         // Data passed to REVERT
+	RevertReason    string         // RevertData decoded by UnpackRevert, empty if it is not an Error(string) or Panic(uint256)
+	Failure         FailureKind    // FailureNone on success
+	Err             error          // Error the execution failed with
+}
+
+// newExecutionResult returns the result of an execution that ran with
+// quotaInit and ended with ret and err.
+func (vm *VM) newExecutionResult(quotaInit uint64, ret []byte, err error) *ExecutionResult {
+	result := &ExecutionResult{
+		ReturnData: ret,
+		Logs:       vm.logs,
+		Txs:        vm.txs,
+		Failure:    failureKind(err),
+		Err:        err,
+	}
+	// running out of quota uses up all of it, there is nothing to refund
+	if err == ErrOutOfQuota {
+		result.QuotaUsed = quotaInit
+	} else {
+		result.QuotaUsed = quotaUsed(quotaInit, vm.quotaLeft, vm.quotaReturn)
+		result.QuotaRefunded = quotaRefund(quotaInit, vm.quotaLeft, vm.quotaReturn)
+	}
+	if err == ErrExecutionReverted {
+		result.RevertData = ret
+		result.RevertReason, _ = UnpackRevert(ret)
+	}
+	return result
+}
+
+type jsonExecutionResult struct {
+	ReturnData      string            `json:"returnData"`
+	QuotaUsed       string            `json:"quotaUsed"`
+	QuotaRefunded   string            `json:"quotaRefunded"`
+	Logs            []jsonLog         `json:"logs"`
+	Txs             []jsonTransaction `json:"txs"`
+	ContractAddress string            `json:"contractAddress,omitempty"`
+	RevertData      string            `json:"revertData,omitempty"`
+	RevertReason    string            `json:"revertReason,omitempty"`
+	Failure         FailureKind       `json:"failure,omitempty"`
+	Error           string            `json:"error,omitempty"`
+}
+
+type jsonLog struct {
+	Address string   `json:"address"`
+	Topics  []string `json:"topics"`
+	Data    string   `json:"data"`
+	Height  uint64   `json:"height"`
+}
+
+type jsonTransaction struct {
+	From        string `json:"from"`
+	To          string `json:"to"`
+	TxType      int    `json:"txType"`
+	TokenTypeId string `json:"tokenTypeId"`
+	Amount      string `json:"amount"`
+	Data        string `json:"data"`
+	Depth       uint64 `json:"depth"`
+}
+
+func newJSONLog(log *Log) jsonLog {
+	enc := jsonLog{Address: log.Address.String(), Topics: make([]string, len(log.Topics)), Data: hexBytes(log.Data), Height: log.Height}
+	for i, topic := range log.Topics {
+		enc.Topics[i] = hexBytes(topic.Bytes())
+	}
+	return enc
+}
+
+func newJSONTransaction(tx *Transaction) jsonTransaction {
+	enc := jsonTransaction{From: tx.From.String(), To: tx.To.String(), TxType: tx.TxType, TokenTypeId: tx.TokenTypeId.String(), Amount: "0", Data: hexBytes(tx.Data), Depth: tx.Depth}
+	if tx.Amount != nil {
+		enc.Amount = tx.Amount.String()
+	}
+	return enc
+}
+
+// MarshalJSON encodes quota in hex and byte slices as 0x-prefixed hex, like
+// JSONLogger.
+func (r *ExecutionResult) MarshalJSON() ([]byte, error) {
+	enc := jsonExecutionResult{
+		ReturnData:    hexBytes(r.ReturnData),
+		QuotaUsed:     hexUint64(r.QuotaUsed),
+		QuotaRefunded: hexUint64(r.QuotaRefunded),
+		Logs:          make([]jsonLog, len(r.Logs)),
+		Txs:           make([]jsonTransaction, len(r.Txs)),
+		Failure:       r.Failure,
+	}
+	for i, log := range r.Logs {
+		enc.Logs[i] = newJSONLog(log)
+	}
+	for i, tx := range r.Txs {
+		enc.Txs[i] = newJSONTransaction(tx)
+	}
+	if r.ContractAddress != (types.Address{}) {
+		enc.ContractAddress = r.ContractAddress.String()
+	}
+	if r.RevertData != nil {
+		enc.RevertData = hexBytes(r.RevertData)
+		enc.RevertReason = r.RevertReason
+	}
+	if r.Err != nil {
+		enc.Error = r.Err.Error()
+	}
+	return json.Marshal(enc)
+}
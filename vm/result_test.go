@@ -0,0 +1,59 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestExecutionResult(t *testing.T) {
+	db := NewMemoryDatabase()
+	from, to := testAddress(1), testAddress(2)
+	call := func(code string) (*ExecutionResult, map[string]interface{}) {
+		c, _ := hex.DecodeString(code)
+		db.SetContractCode(to, c)
+		vm := NewVM(Transaction{From: from, To: to, Depth: 1, TxType: TxTypeReceive, Amount: big.NewInt(0), AccountHeight: big.NewInt(1)})
+		vm.StateDb = db
+		result, err := vm.Call()
+		if err != result.Err {
+			t.Fatalf("expected error %v in the result, got %v", err, result.Err)
+		}
+		data, err := json.Marshal(result)
+		if err != nil {
+			t.Fatalf("marshal failed, %v", err)
+		}
+		var fields map[string]interface{}
+		json.Unmarshal(data, &fields)
+		return result, fields
+	}
+
+	// log1(0, 0, 7); sstore(0, 0); stop, the refund is capped at half the quota spent
+	db.SetState(to, testHash(0), testHash(1))
+	db.Commit()
+	result, fields := call("600760006000a160006000550000")
+	if result.Failure != FailureNone || result.QuotaRefunded != 13382 || len(result.Logs) != 1 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if fields["quotaRefunded"] != "0x3446" || fields["failure"] != nil || fields["error"] != nil {
+		t.Fatalf("unexpected json %v", fields)
+	}
+	if logs := fields["logs"].([]interface{}); logs[0].(map[string]interface{})["topics"].([]interface{})[0] != hexBytes(testHash(7).Bytes()) {
+		t.Fatalf("unexpected json logs %v", logs)
+	}
+
+	// log1(0, 0, 7); mstore(0, 0xab); revert(31, 1)
+	result, fields = call("600760006000a160ab6000526001601ffd")
+	if result.Failure != FailureReverted || !bytes.Equal(result.RevertData, []byte{0xab}) || result.RevertReason != "" || len(result.Logs) != 0 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if fields["failure"] != "reverted" || fields["revertData"] != "0xab" || fields["error"] != ErrExecutionReverted.Error() {
+		t.Fatalf("unexpected json %v", fields)
+	}
+
+	// invalid opcode
+	if result, fields = call("fe"); result.Failure != FailureInvalid || fields["failure"] != "invalid" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
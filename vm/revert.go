@@ -0,0 +1,73 @@
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/vitelabs/go-vite/common/types"
+	"unicode/utf8"
+)
+
+var (
+	// Selectors of the revert payloads emitted by Solidity, hashed with
+	// BLAKE2B like every other Vite ABI selector.
+	revertSelector = types.DataHash([]byte("Error(string)")).Bytes()[:4]
+	panicSelector  = types.DataHash([]byte("Panic(uint256)")).Bytes()[:4]
+
+	errInvalidRevertData = errors.New("revert data is neither Error(string) nor Panic(uint256)")
+)
+
+// panicReasons describes the Panic(uint256) codes emitted by Solidity.
+var panicReasons = map[uint64]string{
+	0x00: "generic panic",
+	0x01: "assert(false)",
+	0x11: "arithmetic underflow or overflow",
+	0x12: "division or modulo by zero",
+	0x21: "enum overflow",
+	0x22: "invalid encoded storage byte array accessed",
+	0x31: "out-of-bounds array access; popping on an empty array",
+	0x32: "out-of-bounds access of an array or bytesN",
+	0x41: "out of memory",
+	0x51: "uninitialized function",
+}
+
+// UnpackRevert decodes revert data encoded as Error(string), returning the
+// message, or as Panic(uint256), returning a description of the panic code.
+func UnpackRevert(data []byte) (string, error) {
+	if len(data) < 4 {
+		return "", errInvalidRevertData
+	}
+	selector, args := data[:4], data[4:]
+	switch {
+	case bytes.Equal(selector, revertSelector):
+		// offset of the string, then its length and bytes
+		var offsetWord, lengthWord word
+		if len(args) < 32 {
+			return "", errInvalidRevertData
+		}
+		offset, overflow := offsetWord.setBytes(args[:32]).uint64WithOverflow()
+		if overflow || offset > uint64(len(args)) || uint64(len(args))-offset < 32 {
+			return "", errInvalidRevertData
+		}
+		length, overflow := lengthWord.setBytes(args[offset : offset+32]).uint64WithOverflow()
+		if overflow || length > uint64(len(args))-offset-32 {
+			return "", errInvalidRevertData
+		}
+		reason := args[offset+32 : offset+32+length]
+		if !utf8.Valid(reason) {
+			return "", errInvalidRevertData
+		}
+		return string(reason), nil
+	case bytes.Equal(selector, panicSelector):
+		if len(args) != 32 {
+			return "", errInvalidRevertData
+		}
+		var codeWord word
+		code, overflow := codeWord.setBytes(args).uint64WithOverflow()
+		if reason, ok := panicReasons[code]; ok && !overflow {
+			return reason, nil
+		}
+		return fmt.Sprintf("unknown panic code: %#x", codeWord.big()), nil
+	}
+	return "", errInvalidRevertData
+}
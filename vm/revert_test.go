@@ -0,0 +1,47 @@
+package vm
+
+import (
+	"testing"
+)
+
+func TestUnpackRevert(t *testing.T) {
+	uint256 := func(n uint64) []byte {
+		b := new(word).setUint64(n).bytes32()
+		return b[:]
+	}
+	errorData := func(offset, length uint64, reason string) []byte {
+		data := append([]byte{}, revertSelector...)
+		data = append(data, uint256(offset)...)
+		data = append(data, uint256(length)...)
+		return append(data, rightPadBytes([]byte(reason), (len(reason)+31)/32*32)...)
+	}
+	panicData := func(code uint64) []byte {
+		return append(append([]byte{}, panicSelector...), uint256(code)...)
+	}
+
+	tests := []struct {
+		data   []byte
+		reason string
+		valid  bool
+	}{
+		{errorData(32, 18, "insufficient funds"), "insufficient funds", true},
+		{errorData(32, 0, ""), "", true},
+		{errorData(32, 33, "insufficient funds"), "", false},
+		{errorData(1<<63, 18, "insufficient funds"), "", false},
+		{errorData(32, 1<<63, "insufficient funds"), "", false},
+		{errorData(32, 2, "\xff\xfe"), "", false},
+		{panicData(0x11), "arithmetic underflow or overflow", true},
+		{panicData(0x01), "assert(false)", true},
+		{panicData(0x99), "unknown panic code: 0x99", true},
+		{panicData(0x11)[:20], "", false},
+		{[]byte{0xab}, "", false},
+		{nil, "", false},
+		{append([]byte{0x08, 0xc3, 0x79, 0xa0}, errorData(32, 1, "a")[4:]...), "", false},
+	}
+	for i, test := range tests {
+		reason, err := UnpackRevert(test.data)
+		if test.valid != (err == nil) || reason != test.reason {
+			t.Errorf("test %v: expected %q (valid %v), got %q, %v", i, test.reason, test.valid, reason, err)
+		}
+	}
+}
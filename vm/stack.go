@@ -5,26 +5,29 @@ import (
 	"math/big"
 )
 
+// stack holds items by value so that pushing and popping never allocates.
+// Pointers returned by peek and back point into the stack and are only valid
+// until the next push or pop.
 type stack struct {
-	data []*big.Int
+	data []word
 }
 
 func newStack() *stack {
-	return &stack{data: make([]*big.Int, 0, stackLimit)}
+	return &stack{data: make([]word, 0, stackLimit)}
 }
 
-func (st *stack) push(d *big.Int) {
-	st.data = append(st.data, d)
+func (st *stack) push(d *word) {
+	st.data = append(st.data, *d)
 }
 
-func (st *stack) pop() (ret *big.Int) {
+func (st *stack) pop() (ret word) {
 	ret = st.data[len(st.data)-1]
 	st.data = st.data[:len(st.data)-1]
 	return
 }
 
-func (st *stack) peek() *big.Int {
-	return st.data[st.len()-1]
+func (st *stack) peek() *word {
+	return &st.data[st.len()-1]
 }
 
 func (st *stack) len() int {
@@ -39,28 +42,23 @@ func (st *stack) require(n int) error {
 }
 
 // back returns the n'th item in stack
-func (st *stack) back(n int) *big.Int {
-	return st.data[st.len()-n-1]
+func (st *stack) back(n int) *word {
+	return &st.data[st.len()-n-1]
 }
 
-func (st *stack) dup(pool *intPool, n int) {
-	st.push(pool.get().Set(st.data[st.len()-n]))
+func (st *stack) dup(n int) {
+	st.push(&st.data[st.len()-n])
 }
 
 func (st *stack) swap(n int) {
 	st.data[st.len()-n], st.data[st.len()-1] = st.data[st.len()-1], st.data[st.len()-n]
 }
 
-func (st *stack) string() string {
-	var result string
-	if len(st.data) > 0 {
-		for i, val := range st.data {
-			if i == len(st.data)-1 {
-				result += val.Text(16)
-			} else {
-				result += val.Text(16) + ", "
-			}
-		}
+// bigs returns a copy of the stack items as big integers, for tracers.
+func (st *stack) bigs() []*big.Int {
+	items := make([]*big.Int, len(st.data))
+	for i := range st.data {
+		items[i] = st.data[i].big()
 	}
-	return result
+	return items
 }
@@ -0,0 +1,246 @@
+package vm
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/vitelabs/go-vite/common/types"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// StateTest is a conformance test: a transaction run against a prestate and
+// the outcome it must have. State tests are stored as JSON objects mapping
+// test names to tests, pre and post encoded like MemoryDatabase:
+//
+//	{
+//	  "name": {
+//	    "config": {"leafHeight": 0},
+//	    "env":    {"accountHeight": 1, "snapshotHeight": 1, "snapshotTimestamp": 0},
+//	    "pre":    {"accounts": {...}, "hashes": {...}},
+//	    "tx":     {"create": false, "from": "vite_...", "to": "vite_...", "txType": 2,
+//	               "tokenTypeId": "tti_...", "amount": "0", "data": "0x", "depth": 1, "quota": 1000000},
+//	    "expect": {"post": {...}, "returnData": "0x", "quotaUsed": 21000, "quotaRefunded": 0,
+//	               "logs": [...], "txs": [...], "contractAddress": "vite_...", "failure": "reverted"}
+//	  }
+//	}
+//
+// Tests without "config" run with DefaultChainConfig. Fields left out of
+// "expect" are not checked, an empty "logs" or "txs" array checks that there
+// are none.
+type StateTest struct {
+	Config *ChainConfig         `json:"config"`
+	Env    StateTestEnv         `json:"env"`
+	Pre    json.RawMessage      `json:"pre"`
+	Tx     StateTestTransaction `json:"tx"`
+	Expect StateTestExpect      `json:"expect"`
+}
+
+// StateTestEnv is the environment of the transaction of a StateTest.
+type StateTestEnv struct {
+	AccountHeight     uint64 `json:"accountHeight"`
+	SnapshotHeight    uint64 `json:"snapshotHeight"`
+	SnapshotTimestamp uint64 `json:"snapshotTimestamp"`
+}
+
+// StateTestTransaction is the transaction of a StateTest, run with VM.Create
+// if Create is set and VM.Call otherwise.
+type StateTestTransaction struct {
+	Create      bool   `json:"create"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	TxType      int    `json:"txType"`
+	TokenTypeId string `json:"tokenTypeId"`
+	Amount      string `json:"amount"`
+	Data        string `json:"data"`
+	Depth       uint64 `json:"depth"`
+	Quota       uint64 `json:"quota"` // DefaultQuotaParams.MaxQuota if zero
+}
+
+// StateTestExpect is the outcome expected by a StateTest.
+type StateTestExpect struct {
+	Post            json.RawMessage   `json:"post"`
+	ReturnData      *string           `json:"returnData"`
+	QuotaUsed       *uint64           `json:"quotaUsed"`
+	QuotaRefunded   *uint64           `json:"quotaRefunded"`
+	Logs            []jsonLog         `json:"logs"`
+	Txs             []jsonTransaction `json:"txs"`
+	ContractAddress *string           `json:"contractAddress"`
+	Failure         *string           `json:"failure"`
+}
+
+// Run runs the test against a new MemoryDatabase holding the prestate. It
+// returns an error listing every difference to the expected outcome.
+func (t *StateTest) Run() error {
+	db := NewMemoryDatabase()
+	if err := json.Unmarshal(t.Pre, db); err != nil {
+		return fmt.Errorf("invalid pre: %v", err)
+	}
+	tx, err := t.Tx.transaction()
+	if err != nil {
+		return err
+	}
+	tx.AccountHeight = new(big.Int).SetUint64(t.Env.AccountHeight)
+	tx.SnapshotHeight = new(big.Int).SetUint64(t.Env.SnapshotHeight)
+	tx.SnapshotTimestamp = new(big.Int).SetUint64(t.Env.SnapshotTimestamp)
+
+	config := t.Config
+	if config == nil {
+		config = DefaultChainConfig
+	}
+	vm := NewVMWithChainConfig(tx, config)
+	vm.StateDb = db
+	vm.QuotaProvider = FixedQuotaProvider(DefaultQuotaParams.MaxQuota)
+	if t.Tx.Quota != 0 {
+		vm.QuotaProvider = FixedQuotaProvider(t.Tx.Quota)
+	}
+	var result *ExecutionResult
+	if t.Tx.Create {
+		result, _ = vm.Create()
+	} else {
+		result, _ = vm.Call()
+	}
+	return t.Expect.check(result, db)
+}
+
+func (tx *StateTestTransaction) transaction() (Transaction, error) {
+	t := Transaction{TxType: tx.TxType, Depth: tx.Depth, Amount: new(big.Int)}
+	var err error
+	if tx.From != "" {
+		if t.From, err = types.HexToAddress(tx.From); err != nil {
+			return t, fmt.Errorf("invalid tx from %q: %v", tx.From, err)
+		}
+	}
+	if tx.To != "" {
+		if t.To, err = types.HexToAddress(tx.To); err != nil {
+			return t, fmt.Errorf("invalid tx to %q: %v", tx.To, err)
+		}
+	}
+	if tx.TokenTypeId != "" {
+		if t.TokenTypeId, err = types.HexToTokenTypeId(tx.TokenTypeId); err != nil {
+			return t, fmt.Errorf("invalid tx tokenTypeId %q: %v", tx.TokenTypeId, err)
+		}
+	}
+	if tx.Amount != "" {
+		if _, ok := t.Amount.SetString(tx.Amount, 10); !ok {
+			return t, fmt.Errorf("invalid tx amount %q", tx.Amount)
+		}
+	}
+	if t.Data, err = hex.DecodeString(strings.TrimPrefix(tx.Data, "0x")); err != nil {
+		return t, fmt.Errorf("invalid tx data: %v", err)
+	}
+	return t, nil
+}
+
+func (e *StateTestExpect) check(result *ExecutionResult, db *MemoryDatabase) error {
+	var diffs []string
+	mismatch := func(field string, expected, got interface{}) {
+		diffs = append(diffs, fmt.Sprintf("%v: expected %v, got %v", field, expected, got))
+	}
+
+	if e.ReturnData != nil && *e.ReturnData != hexBytes(result.ReturnData) {
+		mismatch("returnData", *e.ReturnData, hexBytes(result.ReturnData))
+	}
+	if e.QuotaUsed != nil && *e.QuotaUsed != result.QuotaUsed {
+		mismatch("quotaUsed", *e.QuotaUsed, result.QuotaUsed)
+	}
+	if e.QuotaRefunded != nil && *e.QuotaRefunded != result.QuotaRefunded {
+		mismatch("quotaRefunded", *e.QuotaRefunded, result.QuotaRefunded)
+	}
+	if e.Failure != nil && *e.Failure != result.Failure.String() {
+		mismatch("failure", fmt.Sprintf("%q", *e.Failure), fmt.Sprintf("%q (%v)", result.Failure, result.Err))
+	}
+	if e.ContractAddress != nil {
+		got := ""
+		if result.ContractAddress != (types.Address{}) {
+			got = result.ContractAddress.String()
+		}
+		if *e.ContractAddress != got {
+			mismatch("contractAddress", fmt.Sprintf("%q", *e.ContractAddress), fmt.Sprintf("%q", got))
+		}
+	}
+	if e.Logs != nil {
+		logs := make([]jsonLog, len(result.Logs))
+		for i, log := range result.Logs {
+			logs[i] = newJSONLog(log)
+		}
+		if !reflect.DeepEqual(e.Logs, logs) {
+			mismatch("logs", fmt.Sprintf("%+v", e.Logs), fmt.Sprintf("%+v", logs))
+		}
+	}
+	if e.Txs != nil {
+		txs := make([]jsonTransaction, len(result.Txs))
+		for i, tx := range result.Txs {
+			txs[i] = newJSONTransaction(tx)
+		}
+		if !reflect.DeepEqual(e.Txs, txs) {
+			mismatch("txs", fmt.Sprintf("%+v", e.Txs), fmt.Sprintf("%+v", txs))
+		}
+	}
+	if e.Post != nil {
+		postDiffs, err := diffPostState(e.Post, db)
+		if err != nil {
+			return err
+		}
+		diffs = append(diffs, postDiffs...)
+	}
+
+	if len(diffs) > 0 {
+		return errors.New(strings.Join(diffs, "\n"))
+	}
+	return nil
+}
+
+// diffPostState compares db with the expected state account by account.
+func diffPostState(expected json.RawMessage, db *MemoryDatabase) ([]string, error) {
+	// decoding into a MemoryDatabase and encoding again normalizes the
+	// expectation, it may use 0x prefixes and zero storage values
+	expectedDb := NewMemoryDatabase()
+	if err := json.Unmarshal(expected, expectedDb); err != nil {
+		return nil, fmt.Errorf("invalid expected post: %v", err)
+	}
+	var want, got jsonMemoryDatabase
+	for _, d := range []struct {
+		db  *MemoryDatabase
+		enc *jsonMemoryDatabase
+	}{{expectedDb, &want}, {db, &got}} {
+		data, err := json.Marshal(d.db)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, d.enc); err != nil {
+			return nil, err
+		}
+	}
+
+	addrs := make(map[string]bool)
+	for addr := range want.Accounts {
+		addrs[addr] = true
+	}
+	for addr := range got.Accounts {
+		addrs[addr] = true
+	}
+	sorted := make([]string, 0, len(addrs))
+	for addr := range addrs {
+		sorted = append(sorted, addr)
+	}
+	sort.Strings(sorted)
+
+	var diffs []string
+	for _, addr := range sorted {
+		wantAccount, wantOk := want.Accounts[addr]
+		gotAccount, gotOk := got.Accounts[addr]
+		switch {
+		case !gotOk:
+			diffs = append(diffs, fmt.Sprintf("post %v: missing account", addr))
+		case !wantOk:
+			diffs = append(diffs, fmt.Sprintf("post %v: unexpected account %+v", addr, gotAccount))
+		case !reflect.DeepEqual(wantAccount, gotAccount):
+			diffs = append(diffs, fmt.Sprintf("post %v: expected %+v, got %+v", addr, wantAccount, gotAccount))
+		}
+	}
+	return diffs, nil
+}
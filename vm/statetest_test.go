@@ -0,0 +1,60 @@
+package vm
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStateTests(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "statetests", "*.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no state tests found, %v", err)
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var tests map[string]*StateTest
+		if err := json.Unmarshal(data, &tests); err != nil {
+			t.Fatalf("%v: %v", file, err)
+		}
+		for name, test := range tests {
+			test := test
+			t.Run(strings.TrimSuffix(filepath.Base(file), ".json")+"/"+name, func(t *testing.T) {
+				if err := test.Run(); err != nil {
+					t.Error(err)
+				}
+			})
+		}
+	}
+}
+
+func TestStateTestReportsDifferences(t *testing.T) {
+	from, to := testAddress(1), testAddress(2)
+	// sstore(0, 1); stop
+	test := &StateTest{
+		Pre: json.RawMessage(`{"accounts": {"` + to.String() + `": {"code": "0x600160005500"}}}`),
+		Tx:  StateTestTransaction{From: from.String(), To: to.String(), TxType: TxTypeReceive, Depth: 1},
+	}
+	if err := json.Unmarshal([]byte(`{
+		"post": {"accounts": {"`+to.String()+`": {"code": "0x600160005500", "storage": {"`+testHash(0).Hex()+`": "`+testHash(2).Hex()+`"}}}},
+		"quotaUsed": 1,
+		"logs": [],
+		"failure": "reverted"
+	}`), &test.Expect); err != nil {
+		t.Fatal(err)
+	}
+	err := test.Run()
+	if err == nil {
+		t.Fatalf("expected differences")
+	}
+	diffs := strings.Split(err.Error(), "\n")
+	if len(diffs) != 3 || !strings.HasPrefix(diffs[0], "quotaUsed: expected 1, got ") ||
+		!strings.HasPrefix(diffs[1], `failure: expected "reverted", got ""`) || !strings.HasPrefix(diffs[2], "post "+to.String()+": expected") {
+		t.Fatalf("unexpected differences:\n%v", err)
+	}
+}
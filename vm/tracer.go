@@ -0,0 +1,68 @@
+package vm
+
+import (
+	"fmt"
+	"github.com/vitelabs/go-vite/common/types"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Tracer is used to collect execution traces from the VM.
+//
+// CaptureState is called before each opcode is executed and CaptureFault when
+// an opcode fails after its state was already captured, REVERT is not a fault.
+// The memory passed to both is the live interpreter memory: it must be treated
+// as read only and must not be retained after the call returns. The stack is a
+// copy converted for the tracer.
+type Tracer interface {
+	CaptureStart(from types.Address, to types.Address, create bool, input []byte, quota uint64, amount *big.Int)
+	CaptureState(vm *VM, pc uint64, op OpCode, quota, cost uint64, memory []byte, stack []*big.Int, contractAddr types.Address, depth int, err error)
+	CaptureFault(vm *VM, pc uint64, op OpCode, quota, cost uint64, memory []byte, stack []*big.Int, contractAddr types.Address, depth int, err error)
+	CaptureEnd(output []byte, quotaUsed uint64, t time.Duration, err error)
+}
+
+// postStateTracer is implemented by tracers that also capture the state after
+// each opcode is executed.
+type postStateTracer interface {
+	capturePostState(vm *VM, pc uint64, op OpCode, memory []byte, stack []*big.Int, contractAddr types.Address)
+}
+
+// DebugTracer dumps the stack, memory and contract storage after every
+// opcode. It is the tracer used when VMConfig.Debug is set without a Tracer.
+type DebugTracer struct {
+	out io.Writer
+}
+
+// NewDebugTracer returns a DebugTracer writing to out.
+func NewDebugTracer(out io.Writer) *DebugTracer {
+	return &DebugTracer{out: out}
+}
+
+func (t *DebugTracer) CaptureStart(from types.Address, to types.Address, create bool, input []byte, quota uint64, amount *big.Int) {
+}
+
+func (t *DebugTracer) CaptureState(vm *VM, pc uint64, op OpCode, quota, cost uint64, mem []byte, st []*big.Int, contractAddr types.Address, depth int, err error) {
+}
+
+func (t *DebugTracer) capturePostState(vm *VM, pc uint64, op OpCode, mem []byte, st []*big.Int, contractAddr types.Address) {
+	fmt.Fprintln(t.out, "--------------------")
+	fmt.Fprintf(t.out, "op: %v, pc: %v\nstack: [%v]\nmemory: [%v]\nstorage: [%v]\n", op, pc, stackString(st), (&memory{store: mem}).string(), vm.StateDb.GetStatesString(contractAddr))
+	fmt.Fprintln(t.out, "--------------------")
+}
+
+func (t *DebugTracer) CaptureFault(vm *VM, pc uint64, op OpCode, quota, cost uint64, mem []byte, st []*big.Int, contractAddr types.Address, depth int, err error) {
+	fmt.Fprintf(t.out, "op: %v, pc: %v, error: %v\n", op, pc, err)
+}
+
+func (t *DebugTracer) CaptureEnd(output []byte, quotaUsed uint64, d time.Duration, err error) {
+}
+
+func stackString(st []*big.Int) string {
+	items := make([]string, len(st))
+	for i, item := range st {
+		items[i] = item.Text(16)
+	}
+	return strings.Join(items, ", ")
+}
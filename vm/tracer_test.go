@@ -0,0 +1,101 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/vitelabs/go-vite/common/types"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingTracer struct {
+	ops    []OpCode
+	quotas []uint64
+	faults int
+}
+
+func (t *recordingTracer) CaptureStart(from types.Address, to types.Address, create bool, input []byte, quota uint64, amount *big.Int) {
+}
+func (t *recordingTracer) CaptureState(vm *VM, pc uint64, op OpCode, quota, cost uint64, memory []byte, stack []*big.Int, contractAddr types.Address, depth int, err error) {
+	t.ops = append(t.ops, op)
+	t.quotas = append(t.quotas, quota)
+}
+func (t *recordingTracer) CaptureFault(vm *VM, pc uint64, op OpCode, quota, cost uint64, memory []byte, stack []*big.Int, contractAddr types.Address, depth int, err error) {
+	t.faults++
+}
+func (t *recordingTracer) CaptureEnd(output []byte, quotaUsed uint64, d time.Duration, err error) {}
+
+func TestTracer(t *testing.T) {
+	tracer := &recordingTracer{}
+	vm := NewVM(Transaction{})
+	vm.StateDb = &testDatabase{}
+	vm.Tracer = tracer
+	vm.quotaLeft = 1000000
+	// return 1+2
+	code, _ := hex.DecodeString("6001600201602080919052602090F3")
+	c := newContract(types.Address{}, types.Address{}, types.TokenTypeId{}, new(big.Int), code)
+	c.setCallCode(types.Address{}, types.Hash{}, code)
+	if _, err := run(vm, c); err != nil {
+		t.Fatalf("run failed, %v", err)
+	}
+	expected := []OpCode{PUSH1, PUSH1, ADD, PUSH1, DUP1, SWAP2, SWAP1, MSTORE, PUSH1, SWAP1, RETURN}
+	if len(tracer.ops) != len(expected) {
+		t.Fatalf("expected %v steps, got %v", len(expected), len(tracer.ops))
+	}
+	for i, op := range expected {
+		if tracer.ops[i] != op {
+			t.Fatalf("step %v: expected %v, got %v", i, op, tracer.ops[i])
+		}
+	}
+	if tracer.quotas[0] != 1000000 || tracer.quotas[1] != 1000000-fastestStepGas {
+		t.Fatalf("unexpected quota before steps, %v", tracer.quotas[:2])
+	}
+	if tracer.faults != 0 {
+		t.Fatalf("unexpected faults, %v", tracer.faults)
+	}
+}
+
+func TestTracer_Revert(t *testing.T) {
+	tracer := &recordingTracer{}
+	vm := NewVM(Transaction{})
+	vm.StateDb = &testDatabase{}
+	vm.Tracer = tracer
+	vm.quotaLeft = 1000000
+	// revert(0, 0)
+	code, _ := hex.DecodeString("60006000fd")
+	c := newContract(types.Address{}, types.Address{}, types.TokenTypeId{}, new(big.Int), code)
+	c.setCallCode(types.Address{}, types.Hash{}, code)
+	if _, err := run(vm, c); err != ErrExecutionReverted {
+		t.Fatalf("expected %v, got %v", ErrExecutionReverted, err)
+	}
+	if len(tracer.ops) != 3 || tracer.ops[2] != REVERT || tracer.faults != 0 {
+		t.Fatalf("expected REVERT to be captured as a state and not a fault, got %v and %v faults", tracer.ops, tracer.faults)
+	}
+}
+
+func TestDebugTracer(t *testing.T) {
+	var out bytes.Buffer
+	vm := NewVM(Transaction{})
+	vm.StateDb = &testDatabase{}
+	vm.Tracer = NewDebugTracer(&out)
+	vm.quotaLeft = 1000000
+	// 1 + 2
+	code, _ := hex.DecodeString("600160020100")
+	c := newContract(types.Address{}, types.Address{}, types.TokenTypeId{}, new(big.Int), code)
+	c.setCallCode(types.Address{}, types.Hash{}, code)
+	if _, err := run(vm, c); err != nil {
+		t.Fatalf("run failed, %v", err)
+	}
+	// the state is dumped after every opcode
+	for _, step := range []string{
+		"op: PUSH1, pc: 0\nstack: [1]\n",
+		"op: PUSH1, pc: 2\nstack: [1, 2]\n",
+		"op: ADD, pc: 4\nstack: [3]\n",
+	} {
+		if !strings.Contains(out.String(), step) {
+			t.Errorf("expected the dump to contain %q, got\n%v", step, out.String())
+		}
+	}
+}
@@ -4,16 +4,42 @@ Package vm implements the vite virtual machine
 package vm
 
 import (
+	"context"
 	"fmt"
 	"github.com/vitelabs/go-vite/common/types"
+	"github.com/vitelabs/go-vite/crypto"
 	"math/big"
+	"os"
 	"sync/atomic"
+	"time"
 )
 
 type VMConfig struct {
-	Debug bool
+	Debug  bool   // Dumps every step to stdout when no Tracer is set
+	Tracer Tracer // Receives execution traces, nil disables tracing
+
+	// Context aborts the execution with ErrExecutionAborted when it is done,
+	// e.g. on a deadline, and MaxSteps once that many opcodes were executed.
+	// Both bound the time spent independently of the quota, nil and 0 for no
+	// limit.
+	Context  context.Context
+	MaxSteps uint64
+
+	Profiler *Profiler // Aggregates quota and time per opcode and code location, nil disables profiling
+
+	JumpdestCache *JumpdestCache // Caches the jump destination analysis of contract code, nil uses DefaultJumpdestCache
 }
 
+const (
+	TxTypeSend    = 1
+	TxTypeReceive = 2
+	// TxTypeSendCreate marks contract creation sends emitted by CREATE and
+	// CREATE2. The sender is already charged and To holds the address the
+	// contract must be created at, derived from ContractSalt, so only the
+	// receive side runs in VM.Create.
+	TxTypeSendCreate = 3
+)
+
 type Transaction struct {
 	From        types.Address
 	To          types.Address
@@ -22,6 +48,9 @@ type Transaction struct {
 	Amount      *big.Int
 	Data        []byte
 	Depth       uint64
+	Difficulty  *big.Int // PoW difficulty attached to the transaction, nil if none
+
+	ContractSalt *ContractSalt // What To was derived from, on the sends emitted by CREATE and CREATE2
 
 	SnapshotTimestamp *big.Int
 	AccountHeight     *big.Int
@@ -38,73 +67,186 @@ type Log struct {
 type VM struct {
 	Transaction
 	VMConfig
-	StateDb Database
+	StateDb       Database
+	QuotaProvider QuotaProvider
 
 	abort          int32
-	intPool        *intPool
+	steps          uint64 // opcodes executed, limited by MaxSteps
+	depth          int
+	readOnly       bool // set while executing a query or a STATICCALL, state modifying opcodes fail
 	instructionSet [256]operation
+	quotaSchedule  *QuotaSchedule
+	precompiles    map[types.Address]precompiledContract
 	quotaLeft      uint64
 	quotaReturn    uint64
+	callOutOfQuota bool // a DELEGATECALL or STATICCALL ran out of quota and the execution went on
 	logs           []*Log
 	txs            []*Transaction
 	returnData     []byte
+	profileFrames  []*profileFrame // executions in progress, while profiling
 }
 
+// NewVM returns a VM executing tx with the rules of DefaultChainConfig.
 func NewVM(tx Transaction) *VM {
-	vm := &VM{Transaction: tx, instructionSet: simpleInstructionSet, logs: make([]*Log, 0), txs: make([]*Transaction, 0)}
+	return NewVMWithChainConfig(tx, DefaultChainConfig)
+}
+
+// NewVMWithChainConfig returns a VM executing tx with the instruction set,
+// quota schedule and precompiled contracts config has in effect at
+// tx.SnapshotHeight.
+func NewVMWithChainConfig(tx Transaction, config *ChainConfig) *VM {
+	vm := &VM{Transaction: tx, instructionSet: genesisInstructionSet, quotaSchedule: config.quotaSchedule(tx.SnapshotHeight), logs: make([]*Log, 0), txs: make([]*Transaction, 0)}
+	if config.IsLeaf(tx.SnapshotHeight) {
+		vm.instructionSet = leafInstructionSet
+		vm.precompiles = simplePrecompiledContracts
+	}
 	return vm
 }
 
+// Cancel aborts the execution with ErrExecutionAborted. It is safe to call
+// from another goroutine.
 func (vm *VM) Cancel() {
 	atomic.StoreInt32(&vm.abort, 1)
 }
 
+// watchContext cancels the execution once vm.Context is done, the returned
+// function stops watching.
+func (vm *VM) watchContext() (stop func()) {
+	if vm.Context == nil {
+		return func() {}
+	}
+	if vm.Context.Err() != nil {
+		vm.Cancel()
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-vm.Context.Done():
+			vm.Cancel()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// tracer returns the tracer in effect, or nil if tracing is disabled.
+func (vm *VM) tracer() Tracer {
+	if vm.Tracer != nil {
+		return vm.Tracer
+	}
+	if vm.Debug {
+		return NewDebugTracer(os.Stdout)
+	}
+	return nil
+}
+
 var (
 	viteTokenTypeId = types.TokenTypeId{}
 )
 
-func calcQuota() uint64 {
-	// TODO calculate quota, use 1000000 for test
-	return 1000000
+// quotaProvider returns the configured QuotaProvider. Without one every
+// transaction gets DefaultQuotaParams.MaxQuota and creating contracts is free.
+func (vm *VM) quotaProvider() QuotaProvider {
+	if vm.QuotaProvider != nil {
+		return vm.QuotaProvider
+	}
+	return FixedQuotaProvider(DefaultQuotaParams.MaxQuota)
 }
 
-func calcCreateContractFee() *big.Int {
-	// TODO calculate service fee for create contract, use 0 for test
-	return big0
+// jumpdestCache returns the configured JumpdestCache, or DefaultJumpdestCache.
+func (vm *VM) jumpdestCache() *JumpdestCache {
+	if vm.JumpdestCache != nil {
+		return vm.JumpdestCache
+	}
+	return DefaultJumpdestCache
 }
 
 func canTransfer(db Database, addr types.Address, tokenTypeId types.TokenTypeId, tokenAmount *big.Int, feeAmount *big.Int) bool {
 	return tokenAmount.Cmp(db.GetBalance(addr, tokenTypeId)) <= 0 && feeAmount.Cmp(db.GetBalance(addr, viteTokenTypeId)) <= 0
 }
 
-func (vm *VM) Create() (contractAddr types.Address, quota uint64, logs []*Log, txs []*Transaction, err error) {
+// Tags of the preimages of contract addresses, so that addresses derived by
+// CREATE, CREATE2 and the receive of a contract creation send never collide.
+const (
+	createAddressTag byte = iota + 1
+	create2AddressTag
+	receiveCreateAddressTag
+)
+
+// contractAddress derives the address of a contract created by creator at
+// accountHeight, tag tells apart the derivations and salt the contracts
+// created at the same height.
+func contractAddress(tag byte, creator types.Address, accountHeight *big.Int, salt ...[]byte) types.Address {
+	data := append([][]byte{{tag}, creator.Bytes(), heightBytes(accountHeight)}, salt...)
+	addr, _ := types.BytesToAddress(crypto.Hash(types.AddressSize, data...))
+	return addr
+}
+
+// ContractSalt is what CREATE or CREATE2 derived the address of a contract
+// from, besides the creator and the code. The receive of the send they emit
+// derives the address again rather than trusting its To.
+type ContractSalt struct {
+	Create2       bool     // CREATE2 rather than CREATE
+	AccountHeight *big.Int // Account height of the creator
+	Salt          []byte   // Sends emitted before CREATE, salt of CREATE2
+}
+
+// address returns the address of the contract creator creates with code.
+func (s *ContractSalt) address(creator types.Address, code []byte) types.Address {
+	if s.Create2 {
+		return contractAddress(create2AddressTag, creator, s.AccountHeight, s.Salt, types.DataHash(code).Bytes())
+	}
+	return contractAddress(createAddressTag, creator, s.AccountHeight, s.Salt)
+}
+
+func (vm *VM) Create() (result *ExecutionResult, err error) {
 	// check can make transaction
-	quotaInit := calcQuota()
+	// the creator pays for deploying, the contract has no quota of its own yet
+	quotaInit := vm.quotaProvider().Quota(vm.From, &vm.Transaction)
 	vm.quotaLeft = quotaInit
-	cost, err := intrinsicGasCost(vm.Data, true)
+	defer vm.watchContext()()
+	if tracer := vm.tracer(); tracer != nil {
+		tracer.CaptureStart(vm.From, vm.To, true, vm.Data, quotaInit, vm.Amount)
+		defer func(start time.Time) {
+			tracer.CaptureEnd(result.ReturnData, result.QuotaUsed, time.Since(start), err)
+		}(time.Now())
+	}
+	cost, err := intrinsicGasCost(vm.quotaSchedule, vm.Data, true)
 	if err != nil {
-		return types.Address{}, 0, vm.logs, vm.txs, err
+		return &ExecutionResult{Failure: failureKind(err), Err: err}, err
 	}
 	err = vm.useQuota(cost)
 	if err != nil {
-		return types.Address{}, 0, vm.logs, vm.txs, err
+		return &ExecutionResult{Failure: failureKind(err), Err: err}, err
 	}
 
-	if vm.TxType == 1 {
+	if vm.TxType == TxTypeSend {
 		// send contract create transaction, sub balance and service fee
-		createFee := calcCreateContractFee()
+		createFee := vm.quotaProvider().CreateContractFee(vm.Data)
 		if !canTransfer(vm.StateDb, vm.From, vm.TokenTypeId, vm.Amount, createFee) {
-			return types.Address{}, quotaUsed(quotaInit, vm.quotaLeft, vm.quotaReturn), vm.logs, vm.txs, ErrInsufficientBalance
+			return vm.newExecutionResult(quotaInit, nil, ErrInsufficientBalance), ErrInsufficientBalance
 		}
 		vm.StateDb.SubBalance(vm.From, vm.TokenTypeId, vm.Amount)
 		vm.StateDb.SubBalance(vm.From, viteTokenTypeId, createFee)
-		return types.Address{}, quotaUsed(quotaInit, vm.quotaLeft, vm.quotaReturn), vm.logs, vm.txs, nil
+		return vm.newExecutionResult(quotaInit, nil, nil), nil
 	} else {
 		// receive contract create transaction
-		// create a random address, return ErrContractAddressCreationFail error if fail, retry later
-		contractAddr, _, err := types.CreateAddress()
-		if err != nil || vm.StateDb.IsExistAddress(contractAddr) {
-			return types.Address{}, quotaUsed(quotaInit, vm.quotaLeft, vm.quotaReturn), vm.logs, vm.txs, ErrContractAddressCreationFail
+		// use the address fixed by CREATE/CREATE2 for the sends they emitted once
+		// it is checked against its derivation, otherwise derive it from the send
+		// transaction so that every node creates the contract at the same address,
+		// return ErrContractAddressCreationFail error if it is taken
+		var contractAddr types.Address
+		if vm.TxType == TxTypeSendCreate {
+			if vm.ContractSalt == nil || vm.ContractSalt.address(vm.From, vm.Data) != vm.To {
+				return vm.newExecutionResult(quotaInit, nil, ErrInvalidCreateSend), ErrInvalidCreateSend
+			}
+			contractAddr = vm.To
+		} else {
+			contractAddr = contractAddress(receiveCreateAddressTag, vm.From, vm.AccountHeight, types.DataHash(vm.Data).Bytes(), heightBytes(vm.SnapshotHeight))
+		}
+		if contractAddr == (types.Address{}) || vm.StateDb.IsExistAddress(contractAddr) {
+			return vm.newExecutionResult(quotaInit, nil, ErrContractAddressCreationFail), ErrContractAddressCreationFail
 		}
 
 		errorRevertId := vm.StateDb.Snapshot()
@@ -118,14 +260,14 @@ func (vm *VM) Create() (contractAddr types.Address, quota uint64, logs []*Log, t
 				vm.txs = append(vm.txs, &Transaction{
 					From:        contractAddr,
 					To:          vm.From,
-					TxType:      1,
+					TxType:      TxTypeSend,
 					TokenTypeId: vm.TokenTypeId,
 					Amount:      vm.Amount,
 					Depth:       vm.Depth + 1,
 				})
 			}
 			vm.StateDb.DeleteAccount(contractAddr)
-			return types.Address{}, quotaUsed(quotaInit, vm.quotaLeft, vm.quotaReturn), vm.logs, vm.txs, ErrDepth
+			return vm.newExecutionResult(quotaInit, nil, ErrDepth), ErrDepth
 		}
 
 		// init contract state and set contract code
@@ -133,54 +275,70 @@ func (vm *VM) Create() (contractAddr types.Address, quota uint64, logs []*Log, t
 		contract.setCallCode(contractAddr, types.DataHash(vm.Data), vm.Data)
 		code, err := run(vm, contract)
 		if err == nil {
-			codeCost := uint64(len(code)) * contractCodeGas
-			err = vm.useQuota(codeCost)
+			if codeCost, overflow := SafeMul(uint64(len(code)), vm.quotaSchedule.ContractCode); overflow {
+				err = ErrOutOfQuota
+			} else {
+				err = vm.useQuota(codeCost)
+			}
 			if err == nil {
 				vm.StateDb.SetContractCode(contractAddr, code)
-				return contractAddr, quotaUsed(quotaInit, vm.quotaLeft, vm.quotaReturn), vm.logs, vm.txs, nil
+				result := vm.newExecutionResult(quotaInit, code, nil)
+				result.ContractAddress = contractAddr
+				return result, nil
 			}
 		}
 
-		// revert if out of quota, retry later; refund and delete account otherwise.
-		if err == ErrOutOfQuota {
+		// revert if out of quota or aborted, retry later; refund and delete account otherwise.
+		if err == ErrOutOfQuota || err == ErrExecutionAborted {
 			vm.StateDb.RevertToSnapShot(errorRevertId)
-			return types.Address{}, quotaInit, vm.logs, vm.txs, err
+			return vm.newExecutionResult(quotaInit, code, err), err
 		} else {
 			if vm.Amount.Cmp(big0) > 0 {
 				vm.txs = append(vm.txs, &Transaction{
 					From:        contractAddr,
 					To:          vm.From,
-					TxType:      1,
+					TxType:      TxTypeSend,
 					TokenTypeId: vm.TokenTypeId,
 					Amount:      vm.Amount,
 					Depth:       vm.Depth + 1,
 				})
 			}
 			vm.StateDb.DeleteAccount(contractAddr)
-			return types.Address{}, quotaUsed(quotaInit, vm.quotaLeft, vm.quotaReturn), vm.logs, vm.txs, err
+			return vm.newExecutionResult(quotaInit, code, err), err
 		}
 	}
 }
 
-func (vm *VM) Call() (quota uint64, logs []*Log, txs []*Transaction, err error) {
-	quotaInit := calcQuota()
+func (vm *VM) Call() (result *ExecutionResult, err error) {
+	quotaAddr := vm.To
+	if vm.TxType == TxTypeSend {
+		quotaAddr = vm.From
+	}
+	quotaInit := vm.quotaProvider().Quota(quotaAddr, &vm.Transaction)
 	vm.quotaLeft = quotaInit
-	cost, err := intrinsicGasCost(vm.Data, false)
+	defer vm.watchContext()()
+	if tracer := vm.tracer(); tracer != nil {
+		tracer.CaptureStart(vm.From, vm.To, false, vm.Data, quotaInit, vm.Amount)
+		defer func(start time.Time) {
+			tracer.CaptureEnd(result.ReturnData, result.QuotaUsed, time.Since(start), err)
+		}(time.Now())
+	}
+	cost, err := intrinsicGasCost(vm.quotaSchedule, vm.Data, false)
 	if err != nil {
-		return 0, vm.logs, vm.txs, err
+		return &ExecutionResult{Failure: failureKind(err), Err: err}, err
 	}
 	err = vm.useQuota(cost)
 	if err != nil {
-		return 0, vm.logs, vm.txs, err
+		return &ExecutionResult{Failure: failureKind(err), Err: err}, err
 	}
 
-	if vm.TxType == 1 {
+	if vm.TxType == TxTypeSend {
 		// send
 		if !canTransfer(vm.StateDb, vm.From, vm.TokenTypeId, vm.Amount, big0) {
-			return quotaUsed(quotaInit, vm.quotaLeft, vm.quotaReturn), vm.logs, vm.txs, ErrInsufficientBalance
+			return vm.newExecutionResult(quotaInit, nil, ErrInsufficientBalance), ErrInsufficientBalance
 		}
 		vm.StateDb.SubBalance(vm.From, vm.TokenTypeId, vm.Amount)
-		return quotaUsed(quotaInit, vm.quotaLeft, vm.quotaReturn), vm.logs, vm.txs, nil
+		return vm.newExecutionResult(quotaInit, nil, nil), nil
 	} else {
 		// receive
 		if !vm.StateDb.IsExistAddress(vm.To) {
@@ -189,39 +347,68 @@ func (vm *VM) Call() (quota uint64, logs []*Log, txs []*Transaction, err error)
 		revertId := vm.StateDb.Snapshot()
 		vm.StateDb.AddBalance(vm.To, vm.TokenTypeId, vm.Amount)
 		if vm.StateDb.GetContractCodeSize(vm.To) == 0 {
-			return quotaUsed(quotaInit, vm.quotaLeft, vm.quotaReturn), vm.logs, vm.txs, nil
+			return vm.newExecutionResult(quotaInit, nil, nil), nil
 		}
 		if vm.Depth > callCreateDepth {
-			return quotaUsed(quotaInit, vm.quotaLeft, vm.quotaReturn), vm.logs, vm.txs, ErrDepth
+			return vm.newExecutionResult(quotaInit, nil, ErrDepth), ErrDepth
 		}
 		contract := newContract(vm.From, vm.To, vm.TokenTypeId, vm.Amount, vm.Data)
 		contract.setCallCode(vm.To, vm.StateDb.GetContractCodeHash(vm.To), vm.StateDb.GetContractCode(vm.To))
-		_, err := run(vm, contract)
+		ret, err := run(vm, contract)
 		if err == nil {
-			return quotaUsed(quotaInit, vm.quotaLeft, vm.quotaReturn), vm.logs, vm.txs, nil
+			return vm.newExecutionResult(quotaInit, ret, nil), nil
 		} else {
 			vm.StateDb.RevertToSnapShot(revertId)
-			if err != ErrOutOfQuota && vm.Amount.Cmp(big0) > 0 {
+			if err != ErrOutOfQuota && err != ErrExecutionAborted && vm.Amount.Cmp(big0) > 0 {
 				vm.StateDb.AddBalance(vm.To, vm.TokenTypeId, vm.Amount)
 				vm.txs = append(vm.txs, &Transaction{
 					From:        vm.To,
 					To:          vm.From,
-					TxType:      1,
+					TxType:      TxTypeSend,
 					TokenTypeId: vm.TokenTypeId,
 					Amount:      vm.Amount,
 					Depth:       vm.Depth + 1,
 				})
 			}
-			if err == ErrOutOfQuota {
-				return quotaInit, vm.logs, vm.txs, err
-			} else {
-				return quotaUsed(quotaInit, vm.quotaLeft, vm.quotaReturn), vm.logs, vm.txs, err
-			}
+			return vm.newExecutionResult(quotaInit, ret, err), err
 		}
 	}
 }
 
+// Query executes the contract at To with Data against the current state
+// without modifying it, e.g. to call a getter off-chain. SSTORE, LOG*, CALL,
+// CREATE and CREATE2 fail with ErrWriteProtection, as does any other write to
+// StateDb. Quota is not charged to anyone, a query gets queryQuota.
+func (vm *VM) Query() (ret []byte, err error) {
+	vm.quotaLeft = queryQuota
+	defer vm.watchContext()()
+	if tracer := vm.tracer(); tracer != nil {
+		tracer.CaptureStart(vm.From, vm.To, false, vm.Data, queryQuota, vm.Amount)
+		defer func(start time.Time) {
+			tracer.CaptureEnd(ret, queryQuota-vm.quotaLeft, time.Since(start), err)
+		}(time.Now())
+	}
+
+	db := &readOnlyDatabase{Database: vm.StateDb}
+	vm.StateDb, vm.readOnly = db, true
+	defer func() { vm.StateDb, vm.readOnly = db.Database, false }()
+
+	contract := newContract(vm.From, vm.To, vm.TokenTypeId, vm.Amount, vm.Data)
+	contract.setCallCode(vm.To, vm.StateDb.GetContractCodeHash(vm.To), vm.StateDb.GetContractCode(vm.To))
+	ret, err = run(vm, contract)
+	if err == nil && db.err != nil {
+		return nil, db.err
+	}
+	return ret, err
+}
+
 func (vm *VM) delegateCall(contractAddr types.Address, data []byte) (ret []byte, err error) {
+	if uint64(vm.depth) > callCreateDepth {
+		return nil, ErrDepth
+	}
+	if p, ok := vm.precompiles[contractAddr]; ok {
+		return runPrecompiledContract(vm, p, data)
+	}
 	revertId := vm.StateDb.Snapshot()
 	contract := newContract(vm.From, vm.To, vm.TokenTypeId, vm.Amount, data)
 	contract.setCallCode(contractAddr, vm.StateDb.GetContractCodeHash(contractAddr), vm.StateDb.GetContractCode(contractAddr))
@@ -232,31 +419,108 @@ func (vm *VM) delegateCall(contractAddr types.Address, data []byte) (ret []byte,
 	return ret, err
 }
 
+// staticCall executes the code at contractAddr in its own context without
+// allowing it to modify state.
+func (vm *VM) staticCall(caller, contractAddr types.Address, data []byte) (ret []byte, err error) {
+	if uint64(vm.depth) > callCreateDepth {
+		return nil, ErrDepth
+	}
+	if p, ok := vm.precompiles[contractAddr]; ok {
+		return runPrecompiledContract(vm, p, data)
+	}
+	if !vm.readOnly {
+		vm.readOnly = true
+		defer func() { vm.readOnly = false }()
+	}
+	contract := newContract(caller, contractAddr, viteTokenTypeId, new(big.Int), data)
+	contract.setCallCode(contractAddr, vm.StateDb.GetContractCodeHash(contractAddr), vm.StateDb.GetContractCode(contractAddr))
+	return run(vm, contract)
+}
+
 func run(vm *VM, c *contract) (ret []byte, err error) {
 	if len(c.code) == 0 {
 		return nil, nil
 	}
 
-	vm.intPool = poolOfIntPools.get()
+	vm.returnData = nil
+
+	// side effects to keep if this execution fails, nested executions only
+	// discard what they produced themselves
+	logsLen, txsLen, quotaReturn := len(vm.logs), len(vm.txs), vm.quotaReturn
 	defer func() {
-		poolOfIntPools.put(vm.intPool)
-		vm.intPool = nil
+		if err != nil {
+			vm.quotaReturn = quotaReturn
+			vm.logs = vm.logs[:logsLen]
+			vm.txs = vm.txs[:txsLen]
+		}
 	}()
 
-	vm.returnData = nil
-
 	var (
-		op   opCode
+		op   OpCode
 		mem  = newMemory()
 		st   = newStack()
 		pc   = uint64(0)
 		cost uint64
+
+		tracer    = vm.tracer()
+		pcCopy    uint64 // needed for the deferred tracer
+		quotaCopy uint64 // for tracer to log quota remaining before execution
+		logged    bool   // deferred tracer should ignore already logged steps
 	)
+	postState, _ := tracer.(postStateTracer)
+
+	vm.depth++
+	defer func() { vm.depth-- }()
+
+	var frame *profileFrame
+	if vm.Profiler != nil {
+		frame = &profileFrame{address: c.codeAddr}
+		vm.profileFrames = append(vm.profileFrames, frame)
+		defer func(start time.Time, quotaLeft uint64) {
+			// the step the execution failed at, if any
+			vm.Profiler.endStep(vm.profileFrames, vm.quotaLeft)
+			vm.profileFrames = vm.profileFrames[:len(vm.profileFrames)-1]
+			if n := len(vm.profileFrames); n > 0 {
+				vm.profileFrames[n-1].nested += time.Since(start)
+				vm.profileFrames[n-1].nestedQuota += quotaLeft - vm.quotaLeft
+			}
+		}(time.Now(), vm.quotaLeft)
+	}
+
+	if tracer != nil {
+		defer func() {
+			// a REVERT was captured like any other opcode, it is not a fault
+			if err != nil && err != ErrExecutionReverted {
+				if !logged {
+					tracer.CaptureState(vm, pcCopy, op, quotaCopy, cost, mem.store, st.bigs(), c.address, vm.depth, err)
+				} else {
+					tracer.CaptureFault(vm, pcCopy, op, quotaCopy, cost, mem.store, st.bigs(), c.address, vm.depth, err)
+				}
+			}
+		}()
+	}
 
-	for atomic.LoadInt32(&vm.abort) == 0 {
-		currentPc := pc
+	// a bug must fail the transaction rather than bring down the node, this
+	// runs before the deferred functions above so they see the error
+	defer func() {
+		if r := recover(); r != nil {
+			ret, err = nil, &InternalError{Op: op, Pc: pc, Address: c.address, Stack: st.bigs(), Panic: r}
+		}
+	}()
+
+	for {
+		if atomic.LoadInt32(&vm.abort) != 0 || (vm.MaxSteps > 0 && vm.steps >= vm.MaxSteps) {
+			return nil, ErrExecutionAborted
+		}
+		vm.steps++
+		if tracer != nil {
+			logged, pcCopy, quotaCopy = false, pc, vm.quotaLeft
+		}
 		op = c.getOp(pc)
 		operation := vm.instructionSet[op]
+		if frame != nil {
+			frame.startStep(pc, op, vm.quotaLeft)
+		}
 
 		if !operation.valid {
 			return nil, fmt.Errorf("invalid opcode 0x%x", int(op))
@@ -266,9 +530,13 @@ func run(vm *VM, c *contract) (ret []byte, err error) {
 			return nil, err
 		}
 
+		if vm.readOnly && operation.writes {
+			return nil, ErrWriteProtection
+		}
+
 		var memorySize uint64
 		if operation.memorySize != nil {
-			memSize, overflow := bigUint64(operation.memorySize(st))
+			memSize, overflow := operation.memorySize(st)
 			if overflow {
 				return nil, errGasUintOverflow
 			}
@@ -277,7 +545,11 @@ func run(vm *VM, c *contract) (ret []byte, err error) {
 			}
 		}
 
+		lastGasCost := mem.lastGasCost
 		cost, err = operation.gasCost(vm, c, st, mem, memorySize)
+		if frame != nil {
+			frame.memoryQuota = mem.lastGasCost - lastGasCost
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -290,12 +562,19 @@ func run(vm *VM, c *contract) (ret []byte, err error) {
 			mem.resize(memorySize)
 		}
 
+		if tracer != nil {
+			tracer.CaptureState(vm, pc, op, quotaCopy, cost, mem.store, st.bigs(), c.address, vm.depth, nil)
+			logged = true
+		}
+
 		res, err := operation.execute(&pc, vm, c, mem, st)
 
-		if vm.Debug {
-			fmt.Println("--------------------")
-			fmt.Printf("op: %v, pc: %v\nstack: [%v]\nmemory: [%v]\nstorage: [%v]\n", opCodeToString[op], currentPc, st.string(), mem.string(), vm.StateDb.GetStatesString(c.address))
-			fmt.Println("--------------------")
+		if postState != nil {
+			postState.capturePostState(vm, pcCopy, op, mem.store, st.bigs(), c.address)
+		}
+
+		if frame != nil {
+			vm.Profiler.endStep(vm.profileFrames, vm.quotaLeft)
 		}
 
 		if operation.returns {
@@ -304,26 +583,26 @@ func run(vm *VM, c *contract) (ret []byte, err error) {
 
 		switch {
 		case err != nil:
-			vm.quotaReturn = 0
-			vm.logs = vm.logs[:0]
-			vm.txs = vm.txs[:0]
 			return nil, err
 		case operation.halts:
 			return res, nil
 		case operation.reverts:
-			vm.quotaReturn = 0
-			vm.logs = vm.logs[:0]
-			vm.txs = vm.txs[:0]
 			return res, ErrExecutionReverted
 		case !operation.jumps:
 			pc++
 		}
 	}
-	return nil, nil
 }
 
+// quotaUsed returns the quota spent less the refund.
 func quotaUsed(quotaInit, quotaLeft, quotaReturn uint64) uint64 {
-	return quotaInit - quotaLeft + min(quotaReturn, (quotaInit-quotaLeft)/2)
+	return quotaInit - quotaLeft - quotaRefund(quotaInit, quotaLeft, quotaReturn)
+}
+
+// quotaRefund returns the part of quotaReturn refunded, at most half of the
+// quota spent.
+func quotaRefund(quotaInit, quotaLeft, quotaReturn uint64) uint64 {
+	return min(quotaReturn, (quotaInit-quotaLeft)/2)
 }
 
 func (vm *VM) useQuota(cost uint64) error {
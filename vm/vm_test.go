@@ -2,10 +2,14 @@ package vm
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
+	"errors"
 	"github.com/vitelabs/go-vite/common/types"
+	"math"
 	"math/big"
 	"testing"
+	"time"
 )
 
 func TestRun(t *testing.T) {
@@ -30,10 +34,10 @@ func TestVM_CreateSend(t *testing.T) {
 	vm := NewVM(Transaction{Depth: 1, TxType: 1, TokenTypeId: types.CreateTokenTypeId(), Amount: big.NewInt(10), Data: inputdata})
 	vm.StateDb = &testDatabase{}
 	// vm.Debug = true
-	addr, quotaUsed, _, _, err := vm.Create()
+	result, err := vm.Create()
 	empthAddress := types.Address{}
-	if addr != empthAddress || quotaUsed != 58336 || err != nil {
-		t.Fatalf("send create fail, %v %v %v", addr, quotaUsed, err)
+	if result.ContractAddress != empthAddress || result.QuotaUsed != 58336 || err != nil {
+		t.Fatalf("send create fail, %v %v %v", result.ContractAddress, result.QuotaUsed, err)
 	}
 }
 
@@ -42,9 +46,325 @@ func TestVM_CreateReceive(t *testing.T) {
 	vm := NewVM(Transaction{Depth: 1, TxType: 2, TokenTypeId: types.CreateTokenTypeId(), Amount: big.NewInt(0), Data: inputdata})
 	vm.StateDb = &testDatabase{}
 	// vm.Debug = true
-	addr, quotaUsed, _, _, err := vm.Create()
+	result, err := vm.Create()
 	empthAddress := types.Address{}
-	if addr == empthAddress || quotaUsed != 74008 || err != nil {
-		t.Fatalf("send create fail, %v %v %v", addr, quotaUsed, err)
+	if result.ContractAddress == empthAddress || result.QuotaUsed != 69208 || err != nil {
+		t.Fatalf("send create fail, %v %v %v", result.ContractAddress, result.QuotaUsed, err)
+	}
+}
+
+func TestRunCall(t *testing.T) {
+	tests := []struct {
+		code  string
+		err   error
+		txLen int
+	}{
+		// mstore(0, 42); call(7, 5, 10, 0, 32); stop
+		{"602a60005260206000600a60056007f100", nil, 1},
+		// mstore(0, 42); call(7, 5, 10, 0, 32); revert(0, 0)
+		{"602a60005260206000600a60056007f160006000fd", ErrExecutionReverted, 0},
+	}
+	for i, test := range tests {
+		vm := NewVM(Transaction{Depth: 1})
+		vm.StateDb = &testDatabase{}
+		vm.quotaLeft = 1000000
+		code, _ := hex.DecodeString(test.code)
+		c := newContract(types.Address{}, types.Address{}, types.TokenTypeId{}, new(big.Int), code)
+		c.setCallCode(types.Address{}, types.Hash{}, code)
+		if _, err := run(vm, c); err != test.err {
+			t.Fatalf("test %v: expected error %v, got %v", i, test.err, err)
+		}
+		if len(vm.txs) != test.txLen {
+			t.Fatalf("test %v: expected %v txs, got %v", i, test.txLen, len(vm.txs))
+		}
+		if test.txLen == 0 {
+			continue
+		}
+		tx := vm.txs[0]
+		to, err := types.BytesToAddress(leftPadBytes([]byte{7}, types.AddressSize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokenTypeId, err := types.BytesToTokenTypeId(leftPadBytes([]byte{5}, types.TokenTypeIdSize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tx.To != to || tx.TokenTypeId != tokenTypeId || tx.Amount.Cmp(big.NewInt(10)) != 0 || tx.TxType != TxTypeSend || tx.Depth != 2 {
+			t.Fatalf("test %v: unexpected tx %+v", i, tx)
+		}
+		if !bytes.Equal(tx.Data, leftPadBytes([]byte{42}, 32)) {
+			t.Fatalf("test %v: unexpected tx data %v", i, tx.Data)
+		}
+	}
+}
+
+func TestRunCreate(t *testing.T) {
+	creator := testAddress(1)
+	vm := NewVM(Transaction{Depth: 1, AccountHeight: big.NewInt(3)})
+	vm.StateDb = &testDatabase{}
+	vm.quotaLeft = 1000000
+	// create(0, 0, 0, 0); create(0, 0, 0, 0); create2(0, 0, 0, 0, 1); stop
+	code, _ := hex.DecodeString("6000600060006000f06000600060006000f060016000600060006000f500")
+	c := newContract(types.Address{}, creator, types.TokenTypeId{}, new(big.Int), code)
+	c.setCallCode(creator, types.Hash{}, code)
+	if _, err := run(vm, c); err != nil {
+		t.Fatalf("run failed, %v", err)
+	}
+	if len(vm.txs) != 3 {
+		t.Fatalf("expected 3 txs, got %v", len(vm.txs))
+	}
+	expected := []types.Address{
+		contractAddress(createAddressTag, creator, big.NewInt(3), []byte{0, 0, 0, 0, 0, 0, 0, 0}),
+		contractAddress(createAddressTag, creator, big.NewInt(3), []byte{0, 0, 0, 0, 0, 0, 0, 1}),
+		contractAddress(create2AddressTag, creator, big.NewInt(3), leftPadBytes([]byte{1}, 32), types.DataHash(nil).Bytes()),
+	}
+	for i, tx := range vm.txs {
+		if tx.TxType != TxTypeSendCreate || tx.From != creator || tx.To != expected[i] || tx.ContractSalt.address(creator, nil) != tx.To {
+			t.Fatalf("tx %v: unexpected tx %+v", i, tx)
+		}
+	}
+	if vm.txs[0].To == vm.txs[1].To {
+		t.Fatalf("expected different addresses for consecutive creates")
+	}
+}
+
+func TestVM_CreateReceiveAddress(t *testing.T) {
+	inputdata, _ := hex.DecodeString("608060405260008055348015601357600080fd5b5060358060216000396000f3006080604052600080fd00a165627a7a723058207c31c74808fe0f95820eb3c48eac8e3e10ef27058dc6ca159b547fccde9290790029")
+	sender := testAddress(1)
+	create := func(data []byte, snapshotHeight int64) types.Address {
+		vm := NewVM(Transaction{From: sender, Depth: 1, TxType: TxTypeReceive, Amount: big.NewInt(0), Data: data, AccountHeight: big.NewInt(2), SnapshotHeight: big.NewInt(snapshotHeight)})
+		vm.StateDb = &testDatabase{}
+		result, err := vm.Create()
+		if err != nil {
+			t.Fatalf("receive create fail, %v", err)
+		}
+		return result.ContractAddress
+	}
+	addr := create(inputdata, 10)
+	if addr != create(inputdata, 10) {
+		t.Fatalf("expected the same address for the same send transaction")
+	}
+	if addr == create(inputdata, 11) || addr == create(inputdata[:len(inputdata)-1], 10) {
+		t.Fatalf("expected a different address for a different send transaction")
+	}
+
+	// the address of a send emitted by CREATE or CREATE2 is derived again
+	salt := &ContractSalt{Create2: true, AccountHeight: big.NewInt(2), Salt: leftPadBytes([]byte{1}, 32)}
+	derived := salt.address(sender, inputdata)
+	for _, test := range []struct {
+		txType  int
+		to      types.Address
+		salt    *ContractSalt
+		address types.Address
+		err     error
+	}{
+		{TxTypeReceive, testAddress(9), nil, addr, nil},
+		{TxTypeSendCreate, derived, salt, derived, nil},
+		{TxTypeSendCreate, testAddress(9), salt, types.Address{}, ErrInvalidCreateSend},
+		{TxTypeSendCreate, derived, nil, types.Address{}, ErrInvalidCreateSend},
+		{TxTypeSendCreate, derived, &ContractSalt{AccountHeight: big.NewInt(2), Salt: salt.Salt}, types.Address{}, ErrInvalidCreateSend},
+	} {
+		vm := NewVM(Transaction{From: sender, To: test.to, Depth: 1, TxType: test.txType, Amount: big.NewInt(0), Data: inputdata, AccountHeight: big.NewInt(2), SnapshotHeight: big.NewInt(10), ContractSalt: test.salt})
+		vm.StateDb = &testDatabase{}
+		if result, err := vm.Create(); err != test.err || result.ContractAddress != test.address {
+			t.Fatalf("tx type %v to %v: expected the contract at %v, got %+v %v", test.txType, test.to, test.address, result, err)
+		}
+	}
+}
+
+func TestVM_Query(t *testing.T) {
+	db := NewMemoryDatabase()
+	getter, setter, caller := testAddress(1), testAddress(2), testAddress(3)
+	// mstore(0, sload(0)); return(0, 32)
+	getterCode, _ := hex.DecodeString("60005460005260206000f3")
+	db.SetContractCode(getter, getterCode)
+	db.SetState(getter, testHash(0), testHash(5))
+	// sstore(0, 1); stop
+	setterCode, _ := hex.DecodeString("600160005500")
+	db.SetContractCode(setter, setterCode)
+
+	query := func(to types.Address) ([]byte, error) {
+		vm := NewVM(Transaction{To: to})
+		vm.StateDb = db
+		ret, err := vm.Query()
+		if vm.readOnly || vm.StateDb != db {
+			t.Fatalf("expected the vm to be writable again after the query")
+		}
+		return ret, err
+	}
+
+	if ret, err := query(getter); err != nil || !bytes.Equal(ret, testHash(5).Bytes()) {
+		t.Fatalf("expected %v, got %v %v", testHash(5), ret, err)
+	}
+	if _, err := query(setter); err != ErrWriteProtection {
+		t.Fatalf("expected %v, got %v", ErrWriteProtection, err)
+	}
+	if db.GetState(setter, testHash(0)) != (types.Hash{}) {
+		t.Fatalf("expected storage to be unchanged, got %v", db.GetStatesString(setter))
+	}
+
+	// mstore(32, staticcall(target, 0, 0, 0, 32)); return(0, 64)
+	callerCode := func(target types.Address) []byte {
+		code, _ := hex.DecodeString("6020600060006000" + "73" + hex.EncodeToString(target.Bytes()) + "fa60205260406000f3")
+		return code
+	}
+	db.SetContractCode(caller, callerCode(getter))
+	if ret, err := query(caller); err != nil || !bytes.Equal(ret, append(testHash(5).Bytes(), testHash(1).Bytes()...)) {
+		t.Fatalf("expected the static call to return 5, got %v %v", ret, err)
+	}
+	db.SetContractCode(caller, callerCode(setter))
+	if ret, err := query(caller); err != nil || !bytes.Equal(ret, make([]byte, 64)) {
+		t.Fatalf("expected the static call to fail, got %v %v", ret, err)
+	}
+}
+
+func TestVM_StaticCall(t *testing.T) {
+	db := NewMemoryDatabase()
+	from, to, setter := testAddress(1), testAddress(2), testAddress(3)
+	// sstore(0, 1); stop
+	setterCode, _ := hex.DecodeString("600160005500")
+	db.SetContractCode(setter, setterCode)
+	// sstore(1, staticcall(setter, 0, 0, 0, 0)); sstore(2, 1); stop
+	code, _ := hex.DecodeString("600060006000600073" + hex.EncodeToString(setter.Bytes()) + "fa600155600160025500")
+	db.SetContractCode(to, code)
+
+	vm := NewVM(Transaction{From: from, To: to, Depth: 1, TxType: TxTypeReceive, Amount: big.NewInt(0)})
+	vm.StateDb = db
+	if _, err := vm.Call(); err != nil {
+		t.Fatalf("call failed, %v", err)
+	}
+	if db.GetState(setter, testHash(0)) != (types.Hash{}) {
+		t.Fatalf("expected the static call not to modify state")
+	}
+	if db.GetState(to, testHash(1)) != (types.Hash{}) || db.GetState(to, testHash(2)) != testHash(1) {
+		t.Fatalf("expected the caller to continue writable after the static call, got %v", db.GetStatesString(to))
+	}
+}
+
+func TestVM_StaticCallDepth(t *testing.T) {
+	db := NewMemoryDatabase()
+	to := testAddress(2)
+	// return 1 + staticcall(address) until the depth is exceeded
+	code, err := Assemble("PUSH 32 PUSH 0 PUSH 0 PUSH 0 ADDRESS STATICCALL POP PUSH 0 MLOAD PUSH 1 ADD PUSH 0 MSTORE PUSH 32 PUSH 0 RETURN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetContractCode(to, code)
+
+	vm := NewVM(Transaction{From: testAddress(1), To: to, Depth: 1, TxType: TxTypeReceive, Amount: big.NewInt(0)})
+	vm.StateDb = db
+	ret, err := vm.Query()
+	if err != nil {
+		t.Fatalf("query failed, %v", err)
+	}
+	if frames := new(big.Int).SetBytes(ret); frames.Uint64() != callCreateDepth+1 {
+		t.Fatalf("expected %v nested executions, got %v", callCreateDepth+1, frames)
+	}
+}
+
+func TestRunRecoversPanic(t *testing.T) {
+	db := NewMemoryDatabase()
+	from, to, tokenTypeId := testAddress(1), testAddress(2), types.CreateTokenTypeId()
+	// sstore(0, 1); log0(0, 0); 2 + 3
+	code, _ := hex.DecodeString("600160005560006000a0600360020100")
+	db.SetContractCode(to, code)
+
+	vm := NewVM(Transaction{From: from, To: to, Depth: 1, TxType: TxTypeReceive, TokenTypeId: tokenTypeId, Amount: big.NewInt(10), AccountHeight: big.NewInt(1)})
+	vm.StateDb = db
+	vm.instructionSet[ADD].execute = func(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
+		panic("boom")
+	}
+	result, err := vm.Call()
+	internalErr, ok := err.(*InternalError)
+	if !ok || !errors.Is(err, ErrVMInternal) {
+		t.Fatalf("expected an internal error, got %v", err)
+	}
+	if internalErr.Op != ADD || internalErr.Pc != 14 || internalErr.Address != to || internalErr.Panic != "boom" ||
+		len(internalErr.Stack) != 2 || internalErr.Stack[0].Int64() != 3 || internalErr.Stack[1].Int64() != 2 {
+		t.Fatalf("unexpected internal error %+v", internalErr)
+	}
+	if result.Failure != FailureInternal || len(result.Logs) != 0 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if db.GetState(to, testHash(0)) != (types.Hash{}) {
+		t.Fatalf("expected storage to be reverted, got %v", db.GetStatesString(to))
+	}
+	if len(result.Txs) != 1 || result.Txs[0].To != from || result.Txs[0].Amount.Cmp(big.NewInt(10)) != 0 {
+		t.Fatalf("expected a refund, got %v", result.Txs)
+	}
+}
+
+func TestRunRecoversPanicInDelegateCall(t *testing.T) {
+	db := NewMemoryDatabase()
+	from, to, callee := testAddress(1), testAddress(2), testAddress(3)
+	calleeCode, _ := Assemble("PUSH 3 PUSH 2 ADD STOP")
+	db.SetContractCode(callee, calleeCode)
+	code, err := Assemble("PUSH 1 PUSH 0 SSTORE PUSH 0 PUSH 0 PUSH 0 PUSH 0 PUSH 0x" + hex.EncodeToString(callee.Bytes()) + " DELEGATECALL PUSH 1 SSTORE STOP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetContractCode(to, code)
+
+	vm := NewVM(Transaction{From: from, To: to, Depth: 1, TxType: TxTypeReceive, Amount: big.NewInt(0)})
+	vm.StateDb = db
+	vm.instructionSet[ADD].execute = func(pc *uint64, vm *VM, contract *contract, memory *memory, stack *stack) ([]byte, error) {
+		panic("boom")
+	}
+	result, err := vm.Call()
+	internalErr, ok := err.(*InternalError)
+	if !ok || internalErr.Op != ADD || internalErr.Pc != 4 {
+		t.Fatalf("expected the internal error of the callee, got %v", err)
+	}
+	if result.Failure != FailureInternal {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if db.GetState(to, testHash(0)) != (types.Hash{}) || db.GetState(to, testHash(1)) != (types.Hash{}) {
+		t.Fatalf("expected storage to be reverted, got %v", db.GetStatesString(to))
+	}
+}
+
+func TestVM_Abort(t *testing.T) {
+	from, to, tokenTypeId := testAddress(1), testAddress(2), types.CreateTokenTypeId()
+	// sstore(0, 1); loop forever
+	code, _ := hex.DecodeString("60016000555b600556")
+	expired, cancel := context.WithCancel(context.Background())
+	cancel()
+	deadline, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	tests := []struct {
+		name   string
+		config VMConfig
+		quota  uint64
+	}{
+		{"max steps", VMConfig{MaxSteps: 100}, DefaultQuotaParams.MaxQuota},
+		{"cancelled context", VMConfig{Context: expired}, DefaultQuotaParams.MaxQuota},
+		{"deadline", VMConfig{Context: deadline}, math.MaxUint64},
+	}
+	for _, test := range tests {
+		db := NewMemoryDatabase()
+		db.SetContractCode(to, code)
+		vm := NewVM(Transaction{From: from, To: to, Depth: 1, TxType: TxTypeReceive, TokenTypeId: tokenTypeId, Amount: big.NewInt(10)})
+		vm.StateDb = db
+		vm.VMConfig = test.config
+		vm.QuotaProvider = FixedQuotaProvider(test.quota)
+		result, err := vm.Call()
+		if err != ErrExecutionAborted || result.Failure != FailureAborted {
+			t.Fatalf("%v: expected %v, got %v", test.name, ErrExecutionAborted, err)
+		}
+		// an aborted receive is retried rather than refunded, like one out of quota
+		if db.GetState(to, testHash(0)) != (types.Hash{}) || db.GetBalance(to, tokenTypeId).Sign() != 0 || len(result.Txs) != 0 {
+			t.Fatalf("%v: expected the call to be reverted without refund, got %v %v", test.name, db.GetStatesString(to), result.Txs)
+		}
+	}
+
+	vm := NewVM(Transaction{Depth: 1})
+	vm.StateDb = &testDatabase{}
+	vm.quotaLeft = 1000000
+	vm.MaxSteps = 3
+	c := newContract(types.Address{}, types.Address{}, types.TokenTypeId{}, new(big.Int), code)
+	c.setCallCode(types.Address{}, types.Hash{}, code)
+	if _, err := run(vm, c); err != ErrExecutionAborted || vm.steps != 3 {
+		t.Fatalf("expected to abort after 3 steps, got %v after %v", err, vm.steps)
 	}
 }
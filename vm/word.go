@@ -0,0 +1,502 @@
+package vm
+
+import (
+	"encoding/binary"
+	"math/big"
+	"math/bits"
+)
+
+// word is the 256-bit unsigned integer held in stack items. It is stored as
+// four uint64 limbs, least significant first, signed operations read it as
+// two's complement. Arithmetic wraps modulo 2^256 and, unlike big.Int, never
+// allocates.
+//
+// Methods follow the big.Int convention of storing the result in the
+// receiver and returning it, the receiver may alias any of the arguments.
+type word [4]uint64
+
+func (z *word) clear() *word {
+	*z = word{}
+	return z
+}
+
+func (z *word) set(x *word) *word {
+	*z = *x
+	return z
+}
+
+func (z *word) setUint64(x uint64) *word {
+	*z = word{x}
+	return z
+}
+
+// setBytes sets z to the big-endian integer b, only the last 32 bytes of a
+// longer b are used.
+func (z *word) setBytes(b []byte) *word {
+	if len(b) > 32 {
+		b = b[len(b)-32:]
+	}
+	*z = word{}
+	for i, j := 0, len(b)-1; j >= 0; i, j = i+1, j-1 {
+		z[i/8] |= uint64(b[j]) << (8 * uint(i%8))
+	}
+	return z
+}
+
+// setBig sets z to x modulo 2^256, a nil x is zero.
+func (z *word) setBig(x *big.Int) *word {
+	z.clear()
+	if x == nil {
+		return z
+	}
+	abs := x
+	if x.BitLen() > 256 {
+		abs = new(big.Int).Abs(x)
+		abs.And(abs, tt256m1)
+	}
+	var b [32]byte
+	abs.FillBytes(b[:])
+	z.setBytes(b[:])
+	if x.Sign() < 0 {
+		z.neg(z)
+	}
+	return z
+}
+
+// big returns z as a new big.Int.
+func (z *word) big() *big.Int {
+	b := z.bytes32()
+	return new(big.Int).SetBytes(b[:])
+}
+
+// bytes32 returns z as 32 big-endian bytes.
+func (z *word) bytes32() (b [32]byte) {
+	for i := 0; i < 4; i++ {
+		binary.BigEndian.PutUint64(b[24-8*i:], z[i])
+	}
+	return b
+}
+
+func (z *word) isZero() bool {
+	return z[0]|z[1]|z[2]|z[3] == 0
+}
+
+func (z *word) isUint64() bool {
+	return z[1]|z[2]|z[3] == 0
+}
+
+// uint64WithOverflow returns the lowest 64 bits of z and whether z does not
+// fit in a uint64.
+func (z *word) uint64WithOverflow() (uint64, bool) {
+	return z[0], !z.isUint64()
+}
+
+// isNegative reports whether the sign bit of z is set.
+func (z *word) isNegative() bool {
+	return z[3]>>63 == 1
+}
+
+func (z *word) bitLen() int {
+	for i := 3; i >= 0; i-- {
+		if z[i] != 0 {
+			return i*64 + bits.Len64(z[i])
+		}
+	}
+	return 0
+}
+
+func (z *word) bit(n uint) bool {
+	return n < 256 && z[n/64]>>(n%64)&1 == 1
+}
+
+// byteAt returns the n'th byte of z counted from the most significant one,
+// n must be below 32.
+func (z *word) byteAt(n uint64) byte {
+	return byte(z[3-n/8] >> (56 - 8*(n%8)))
+}
+
+func (z *word) eq(x *word) bool {
+	return *z == *x
+}
+
+// lt reports whether z < x as unsigned integers.
+func (z *word) lt(x *word) bool {
+	_, borrow := bits.Sub64(z[0], x[0], 0)
+	_, borrow = bits.Sub64(z[1], x[1], borrow)
+	_, borrow = bits.Sub64(z[2], x[2], borrow)
+	_, borrow = bits.Sub64(z[3], x[3], borrow)
+	return borrow != 0
+}
+
+func (z *word) gt(x *word) bool {
+	return x.lt(z)
+}
+
+// slt reports whether z < x as two's complement signed integers.
+func (z *word) slt(x *word) bool {
+	if zNeg, xNeg := z.isNegative(), x.isNegative(); zNeg != xNeg {
+		return zNeg
+	}
+	return z.lt(x)
+}
+
+func (z *word) sgt(x *word) bool {
+	return x.slt(z)
+}
+
+func (z *word) add(x, y *word) *word {
+	z.addOverflow(x, y)
+	return z
+}
+
+// addOverflow sets z to x + y and reports whether the sum overflowed.
+func (z *word) addOverflow(x, y *word) bool {
+	var carry uint64
+	z[0], carry = bits.Add64(x[0], y[0], 0)
+	z[1], carry = bits.Add64(x[1], y[1], carry)
+	z[2], carry = bits.Add64(x[2], y[2], carry)
+	z[3], carry = bits.Add64(x[3], y[3], carry)
+	return carry != 0
+}
+
+func (z *word) sub(x, y *word) *word {
+	var borrow uint64
+	z[0], borrow = bits.Sub64(x[0], y[0], 0)
+	z[1], borrow = bits.Sub64(x[1], y[1], borrow)
+	z[2], borrow = bits.Sub64(x[2], y[2], borrow)
+	z[3], _ = bits.Sub64(x[3], y[3], borrow)
+	return z
+}
+
+func (z *word) neg(x *word) *word {
+	return z.sub(&word{}, x)
+}
+
+// abs sets z to the absolute value of x read as a signed integer.
+func (z *word) abs(x *word) *word {
+	if x.isNegative() {
+		return z.neg(x)
+	}
+	return z.set(x)
+}
+
+func (z *word) mul(x, y *word) *word {
+	var res word
+	for i := 0; i < 4; i++ {
+		var carry uint64
+		for j := 0; i+j < 4; j++ {
+			res[i+j], carry = mulAddStep(x[i], y[j], res[i+j], carry)
+		}
+	}
+	*z = res
+	return z
+}
+
+// mulFull returns the full 512-bit product of x and y.
+func mulFull(x, y *word) (res [8]uint64) {
+	for i := 0; i < 4; i++ {
+		var carry uint64
+		for j := 0; j < 4; j++ {
+			res[i+j], carry = mulAddStep(x[i], y[j], res[i+j], carry)
+		}
+		res[i+4] = carry
+	}
+	return res
+}
+
+// mulAddStep returns the low and high limbs of x*y + z + carry, which always
+// fits in 128 bits.
+func mulAddStep(x, y, z, carry uint64) (lo, hi uint64) {
+	hi, lo = bits.Mul64(x, y)
+	var c uint64
+	lo, c = bits.Add64(lo, z, 0)
+	hi += c
+	lo, c = bits.Add64(lo, carry, 0)
+	hi += c
+	return lo, hi
+}
+
+// div sets z to x / y, or zero if y is zero.
+func (z *word) div(x, y *word) *word {
+	if y.isZero() || y.gt(x) {
+		return z.clear()
+	}
+	if x.eq(y) {
+		return z.setUint64(1)
+	}
+	if x.isUint64() {
+		return z.setUint64(x[0] / y[0])
+	}
+	var quot word
+	udivrem(quot[:], x[:], y)
+	*z = quot
+	return z
+}
+
+// mod sets z to x % y, or zero if y is zero.
+func (z *word) mod(x, y *word) *word {
+	if y.isZero() || x.eq(y) {
+		return z.clear()
+	}
+	if x.lt(y) {
+		return z.set(x)
+	}
+	if x.isUint64() {
+		return z.setUint64(x[0] % y[0])
+	}
+	var quot word
+	*z = udivrem(quot[:], x[:], y)
+	return z
+}
+
+// sdiv sets z to x / y rounded towards zero, x and y read as signed
+// integers. The result is zero if y is zero.
+func (z *word) sdiv(x, y *word) *word {
+	neg := x.isNegative() != y.isNegative()
+	var a, b word
+	z.div(a.abs(x), b.abs(y))
+	if neg {
+		z.neg(z)
+	}
+	return z
+}
+
+// smod sets z to x % y with the sign of x, x and y read as signed integers.
+// The result is zero if y is zero.
+func (z *word) smod(x, y *word) *word {
+	neg := x.isNegative()
+	var a, b word
+	z.mod(a.abs(x), b.abs(y))
+	if neg {
+		z.neg(z)
+	}
+	return z
+}
+
+// addMod sets z to (x + y) % m without truncating the sum, or zero if m is
+// zero.
+func (z *word) addMod(x, y, m *word) *word {
+	if m.isZero() {
+		return z.clear()
+	}
+	var sum word
+	if !sum.addOverflow(x, y) {
+		return z.mod(&sum, m)
+	}
+	u := [5]uint64{sum[0], sum[1], sum[2], sum[3], 1}
+	var quot [5]uint64
+	*z = udivrem(quot[:], u[:], m)
+	return z
+}
+
+// mulMod sets z to (x * y) % m without truncating the product, or zero if m
+// is zero.
+func (z *word) mulMod(x, y, m *word) *word {
+	if m.isZero() {
+		return z.clear()
+	}
+	u := mulFull(x, y)
+	var quot [8]uint64
+	*z = udivrem(quot[:], u[:], m)
+	return z
+}
+
+// exp sets z to base**exponent modulo 2^256.
+func (z *word) exp(base, exponent *word) *word {
+	res, b := word{1}, *base
+	for i, n := uint(0), uint(exponent.bitLen()); i < n; i++ {
+		if exponent.bit(i) {
+			res.mul(&res, &b)
+		}
+		b.mul(&b, &b)
+	}
+	*z = res
+	return z
+}
+
+// signExtend sets z to x with the sign bit of its byteNum'th byte, counted
+// from the least significant one, copied into all higher bits. byteNum must
+// be below 31.
+func (z *word) signExtend(x *word, byteNum uint64) *word {
+	bit := uint(byteNum*8 + 7)
+	one := word{1}
+	var mask word
+	mask.lsh(&one, bit).sub(&mask, &one)
+	if x.bit(bit) {
+		return z.or(x, mask.not(&mask))
+	}
+	return z.and(x, &mask)
+}
+
+func (z *word) and(x, y *word) *word {
+	z[0], z[1], z[2], z[3] = x[0]&y[0], x[1]&y[1], x[2]&y[2], x[3]&y[3]
+	return z
+}
+
+func (z *word) or(x, y *word) *word {
+	z[0], z[1], z[2], z[3] = x[0]|y[0], x[1]|y[1], x[2]|y[2], x[3]|y[3]
+	return z
+}
+
+func (z *word) xor(x, y *word) *word {
+	z[0], z[1], z[2], z[3] = x[0]^y[0], x[1]^y[1], x[2]^y[2], x[3]^y[3]
+	return z
+}
+
+func (z *word) not(x *word) *word {
+	z[0], z[1], z[2], z[3] = ^x[0], ^x[1], ^x[2], ^x[3]
+	return z
+}
+
+// lsh sets z to x << n, shifts of 256 or more give zero.
+func (z *word) lsh(x *word, n uint) *word {
+	if n >= 256 {
+		return z.clear()
+	}
+	limbs, n := int(n/64), n%64
+	var res word
+	for i := 3; i >= limbs; i-- {
+		res[i] = x[i-limbs] << n
+		if n > 0 && i > limbs {
+			res[i] |= x[i-limbs-1] >> (64 - n)
+		}
+	}
+	*z = res
+	return z
+}
+
+// rsh sets z to x >> n filling with zeros, shifts of 256 or more give zero.
+func (z *word) rsh(x *word, n uint) *word {
+	if n >= 256 {
+		return z.clear()
+	}
+	limbs, n := int(n/64), n%64
+	var res word
+	for i := 0; i < 4-limbs; i++ {
+		res[i] = x[i+limbs] >> n
+		if n > 0 && i+limbs < 3 {
+			res[i] |= x[i+limbs+1] << (64 - n)
+		}
+	}
+	*z = res
+	return z
+}
+
+// srsh sets z to x >> n filling with the sign bit of x.
+func (z *word) srsh(x *word, n uint) *word {
+	if !x.isNegative() {
+		return z.rsh(x, n)
+	}
+	z.not(x)
+	z.rsh(z, n)
+	return z.not(z)
+}
+
+// udivrem divides u by d, storing the quotient in quot and returning the
+// remainder. d must not be zero and quot must be as long as u. It is Knuth's
+// algorithm D from TAOCP vol. 2, 4.3.1, on 64-bit digits.
+func udivrem(quot, u []uint64, d *word) (rem word) {
+	dLen := 0
+	for i := len(d) - 1; i >= 0; i-- {
+		if d[i] != 0 {
+			dLen = i + 1
+			break
+		}
+	}
+	uLen := 0
+	for i := len(u) - 1; i >= 0; i-- {
+		if u[i] != 0 {
+			uLen = i + 1
+			break
+		}
+	}
+	if uLen < dLen {
+		copy(rem[:], u)
+		return rem
+	}
+
+	// normalize so that the top bit of the divisor is set, the dividend gets
+	// an extra digit for the bits shifted out
+	shift := uint(bits.LeadingZeros64(d[dLen-1]))
+	var dnStorage word
+	dn := dnStorage[:dLen]
+	for i := dLen - 1; i > 0; i-- {
+		dn[i] = d[i]<<shift | d[i-1]>>(64-shift)
+	}
+	dn[0] = d[0] << shift
+
+	var unStorage [9]uint64
+	un := unStorage[:uLen+1]
+	un[uLen] = u[uLen-1] >> (64 - shift)
+	for i := uLen - 1; i > 0; i-- {
+		un[i] = u[i]<<shift | u[i-1]>>(64-shift)
+	}
+	un[0] = u[0] << shift
+
+	if dLen == 1 {
+		var r uint64
+		for j := uLen; j >= 0; j-- {
+			if j < len(quot) {
+				quot[j], r = bits.Div64(r, un[j], dn[0])
+			} else {
+				_, r = bits.Div64(r, un[j], dn[0])
+			}
+		}
+		return word{r >> shift}
+	}
+
+	dh, dl := dn[dLen-1], dn[dLen-2]
+	for j := uLen - dLen; j >= 0; j-- {
+		u2, u1, u0 := un[j+dLen], un[j+dLen-1], un[j+dLen-2]
+
+		// estimate the quotient digit from the top digits, it is at most
+		// one too large after the correction below
+		var qhat, rhat uint64
+		if u2 >= dh {
+			qhat = ^uint64(0)
+		} else {
+			qhat, rhat = bits.Div64(u2, u1, dh)
+			ph, pl := bits.Mul64(qhat, dl)
+			if ph > rhat || (ph == rhat && pl > u0) {
+				qhat--
+			}
+		}
+
+		// multiply and subtract, add back if that went below zero
+		borrow := subMulTo(un[j:j+dLen], dn, qhat)
+		un[j+dLen] = u2 - borrow
+		if u2 < borrow {
+			qhat--
+			un[j+dLen] += addTo(un[j:j+dLen], dn)
+		}
+		quot[j] = qhat
+	}
+
+	for i := 0; i < dLen-1; i++ {
+		rem[i] = un[i]>>shift | un[i+1]<<(64-shift)
+	}
+	rem[dLen-1] = un[dLen-1] >> shift
+	return rem
+}
+
+// subMulTo sets x to x - y*multiplier and returns the borrow out of x.
+func subMulTo(x, y []uint64, multiplier uint64) uint64 {
+	var borrow uint64
+	for i := range y {
+		s, carry1 := bits.Sub64(x[i], borrow, 0)
+		ph, pl := bits.Mul64(y[i], multiplier)
+		t, carry2 := bits.Sub64(s, pl, 0)
+		x[i] = t
+		borrow = ph + carry1 + carry2
+	}
+	return borrow
+}
+
+// addTo sets x to x + y and returns the carry out of x.
+func addTo(x, y []uint64) uint64 {
+	var carry uint64
+	for i := range y {
+		x[i], carry = bits.Add64(x[i], y[i], carry)
+	}
+	return carry
+}
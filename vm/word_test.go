@@ -0,0 +1,182 @@
+package vm
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// randomWord returns values biased towards the edge cases of word arithmetic:
+// zero limbs, small numbers, all ones and values around the sign bit.
+func randomWord(r *rand.Rand) word {
+	var w word
+	switch r.Intn(6) {
+	case 0:
+		w.setUint64(uint64(r.Intn(300)))
+	case 1:
+		w.not(w.setUint64(uint64(r.Intn(300))))
+	case 2:
+		w[3] = 1 << 63
+		w[r.Intn(3)] = r.Uint64()
+	default:
+		for i := range w {
+			if r.Intn(3) > 0 {
+				w[i] = r.Uint64()
+			}
+		}
+	}
+	return w
+}
+
+func TestWordAgainstBig(t *testing.T) {
+	sdiv := func(x, y *big.Int) *big.Int {
+		x, y = S256(new(big.Int).Set(x)), S256(new(big.Int).Set(y))
+		if y.Sign() == 0 {
+			return new(big.Int)
+		}
+		res := new(big.Int).Quo(x, y)
+		return U256(res)
+	}
+	smod := func(x, y *big.Int) *big.Int {
+		x, y = S256(new(big.Int).Set(x)), S256(new(big.Int).Set(y))
+		if y.Sign() == 0 {
+			return new(big.Int)
+		}
+		return U256(new(big.Int).Rem(x, y))
+	}
+	bool2big := func(b bool) *big.Int {
+		if b {
+			return big.NewInt(1)
+		}
+		return new(big.Int)
+	}
+
+	binary := []struct {
+		name string
+		op   func(z, x, y *word)
+		ref  func(x, y *big.Int) *big.Int
+	}{
+		{"add", func(z, x, y *word) { z.add(x, y) }, func(x, y *big.Int) *big.Int { return U256(new(big.Int).Add(x, y)) }},
+		{"sub", func(z, x, y *word) { z.sub(x, y) }, func(x, y *big.Int) *big.Int { return U256(new(big.Int).Sub(x, y)) }},
+		{"mul", func(z, x, y *word) { z.mul(x, y) }, func(x, y *big.Int) *big.Int { return U256(new(big.Int).Mul(x, y)) }},
+		{"div", func(z, x, y *word) { z.div(x, y) }, func(x, y *big.Int) *big.Int {
+			if y.Sign() == 0 {
+				return new(big.Int)
+			}
+			return new(big.Int).Div(x, y)
+		}},
+		{"mod", func(z, x, y *word) { z.mod(x, y) }, func(x, y *big.Int) *big.Int {
+			if y.Sign() == 0 {
+				return new(big.Int)
+			}
+			return new(big.Int).Mod(x, y)
+		}},
+		{"sdiv", func(z, x, y *word) { z.sdiv(x, y) }, sdiv},
+		{"smod", func(z, x, y *word) { z.smod(x, y) }, smod},
+		{"exp", func(z, x, y *word) { z.exp(x, y) }, func(x, y *big.Int) *big.Int { return Exp(new(big.Int).Set(x), y) }},
+		{"lt", func(z, x, y *word) { z.set(bitWord(x.lt(y))) }, func(x, y *big.Int) *big.Int { return bool2big(x.Cmp(y) < 0) }},
+		{"slt", func(z, x, y *word) { z.set(bitWord(x.slt(y))) }, func(x, y *big.Int) *big.Int {
+			return bool2big(S256(new(big.Int).Set(x)).Cmp(S256(new(big.Int).Set(y))) < 0)
+		}},
+	}
+	ternary := []struct {
+		name string
+		op   func(z, x, y, m *word)
+		ref  func(x, y, m *big.Int) *big.Int
+	}{
+		{"addMod", func(z, x, y, m *word) { z.addMod(x, y, m) }, func(x, y, m *big.Int) *big.Int {
+			if m.Sign() == 0 {
+				return new(big.Int)
+			}
+			return new(big.Int).Mod(new(big.Int).Add(x, y), m)
+		}},
+		{"mulMod", func(z, x, y, m *word) { z.mulMod(x, y, m) }, func(x, y, m *big.Int) *big.Int {
+			if m.Sign() == 0 {
+				return new(big.Int)
+			}
+			return new(big.Int).Mod(new(big.Int).Mul(x, y), m)
+		}},
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		x, y, m := randomWord(r), randomWord(r), randomWord(r)
+		xb, yb, mb := x.big(), y.big(), m.big()
+		for _, test := range binary {
+			var z word
+			test.op(&z, &x, &y)
+			if expected := test.ref(xb, yb); z.big().Cmp(expected) != 0 {
+				t.Fatalf("%v(%x, %x): expected %x, got %x", test.name, xb, yb, expected, z.big())
+			}
+		}
+		for _, test := range ternary {
+			var z word
+			test.op(&z, &x, &y, &m)
+			if expected := test.ref(xb, yb, mb); z.big().Cmp(expected) != 0 {
+				t.Fatalf("%v(%x, %x, %x): expected %x, got %x", test.name, xb, yb, mb, expected, z.big())
+			}
+		}
+
+		n := uint(r.Intn(260))
+		var z word
+		if expected := U256(new(big.Int).Lsh(xb, n)); z.lsh(&x, n).big().Cmp(expected) != 0 {
+			t.Fatalf("lsh(%x, %v): expected %x, got %x", xb, n, expected, z.big())
+		}
+		if expected := new(big.Int).Rsh(xb, n); z.rsh(&x, n).big().Cmp(expected) != 0 {
+			t.Fatalf("rsh(%x, %v): expected %x, got %x", xb, n, expected, z.big())
+		}
+		if expected := U256(new(big.Int).Rsh(S256(new(big.Int).Set(xb)), n)); z.srsh(&x, n).big().Cmp(expected) != 0 {
+			t.Fatalf("srsh(%x, %v): expected %x, got %x", xb, n, expected, z.big())
+		}
+
+		back := uint64(r.Intn(31))
+		bit := uint(back*8 + 7)
+		mask := new(big.Int).Sub(new(big.Int).Lsh(big1, bit), big1)
+		expected := new(big.Int).And(xb, mask)
+		if xb.Bit(int(bit)) > 0 {
+			expected = U256(new(big.Int).Or(xb, new(big.Int).Not(mask)))
+		}
+		if z.signExtend(&x, back).big().Cmp(expected) != 0 {
+			t.Fatalf("signExtend(%x, %v): expected %x, got %x", xb, back, expected, z.big())
+		}
+
+		if z.setBig(xb); z != x {
+			t.Fatalf("setBig(%x): got %x", xb, z.big())
+		}
+	}
+}
+
+func bitWord(b bool) *word {
+	if b {
+		return &word{1}
+	}
+	return &word{}
+}
+
+func TestOpsDoNotAllocate(t *testing.T) {
+	vm := NewVM(Transaction{})
+	vm.StateDb = &testDatabase{}
+	st := newStack()
+	x := word{0xffffffffffffffff, 0x0807060504030201, 0xabcdef0908070605, 0x1234}
+	for _, op := range []struct {
+		name string
+		fn   executionFunc
+		args int
+	}{
+		{"ADD", opAdd, 2}, {"MUL", opMul, 2}, {"DIV", opDiv, 2}, {"SDIV", opSdiv, 2},
+		{"MOD", opMod, 2}, {"EXP", opExp, 2}, {"SIGNEXTEND", opSignExtend, 2},
+		{"ADDMOD", opAddmod, 3}, {"MULMOD", opMulmod, 3}, {"SAR", opSAR, 2}, {"DUP1", makeDup(1), 1},
+	} {
+		pc := uint64(0)
+		allocs := testing.AllocsPerRun(100, func() {
+			st.data = st.data[:0]
+			for i := 0; i < op.args; i++ {
+				st.push(&x)
+			}
+			op.fn(&pc, vm, nil, nil, st)
+		})
+		if allocs != 0 {
+			t.Errorf("%v: expected no allocations, got %v", op.name, allocs)
+		}
+	}
+}